@@ -0,0 +1,364 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ArgType int
+
+const (
+	StringArg ArgType = iota
+	IntArg
+	DurationArg
+	BoolArg
+	UserArg
+	ChannelArg
+)
+
+var (
+	userMentionPattern    = regexp.MustCompile(`\A<@([UW][A-Z0-9]+)(?:\|([^>]*))?>\z`)
+	channelMentionPattern = regexp.MustCompile(`\A<#(C[A-Z0-9]+)(?:\|([^>]*))?>\z`)
+)
+
+// ArgSpec declares an argument of the command.
+type ArgSpec struct {
+	Name        string
+	Type        ArgType
+	Description string
+	// Flag makes the argument to be given as --name=value.
+	// The flag of BoolArg can be given as --name.
+	Flag     bool
+	Required bool
+	Default  string
+	// Rest makes the positional argument to take all remaining words.
+	Rest bool
+}
+
+// ArgSpecs is the declaration of all arguments of the command.
+type ArgSpecs []ArgSpec
+
+// ArgumentError is returned when the input doesn't satisfy ArgSpecs.
+type ArgumentError struct {
+	Message string
+}
+
+func (e *ArgumentError) Error() string {
+	return e.Message
+}
+
+// Arguments holds the parsed values.
+type Arguments struct {
+	values map[string]interface{}
+}
+
+// Args makes the command validate and parse arguments before the callback runs.
+// The parsed values are available via Event.Args.
+func Args(specs ...ArgSpec) CommandOption {
+	return func(command *Command) {
+		command.args = ArgSpecs(specs)
+	}
+}
+
+// Parse parses text according to the specs.
+func (specs ArgSpecs) Parse(text string) (*Arguments, error) {
+	words, err := splitWords(text)
+	if err != nil {
+		return nil, err
+	}
+
+	args := &Arguments{values: make(map[string]interface{})}
+	positional := make([]word, 0, len(words))
+	flags := make([]word, 0)
+	for _, w := range words {
+		if !strings.HasPrefix(w.value, "--") || len(w.value) == 2 {
+			positional = append(positional, w)
+			continue
+		}
+		flags = append(flags, w)
+
+		name, value := w.value[2:], ""
+		hasValue := false
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value, hasValue = name[:i], name[i+1:], true
+		}
+		spec, ok := specs.flag(name)
+		if !ok {
+			return nil, &ArgumentError{Message: fmt.Sprintf("unknown flag: --%s", name)}
+		}
+		if !hasValue {
+			if spec.Type != BoolArg {
+				return nil, &ArgumentError{Message: fmt.Sprintf("flag needs a value: --%s=<%s>", name, spec.Type)}
+			}
+			value = "true"
+		}
+		if err := args.set(spec, value); err != nil {
+			return nil, err
+		}
+	}
+
+	i := 0
+	for _, spec := range specs {
+		if spec.Flag {
+			continue
+		}
+		if i >= len(positional) {
+			break
+		}
+		value := positional[i].value
+		if spec.Rest {
+			value = restText(text, positional[i:], flags)
+			i = len(positional)
+		} else {
+			i++
+		}
+		if err := args.set(spec, value); err != nil {
+			return nil, err
+		}
+	}
+	if i < len(positional) {
+		rest := make([]string, 0, len(positional)-i)
+		for _, w := range positional[i:] {
+			rest = append(rest, w.value)
+		}
+		return nil, &ArgumentError{Message: fmt.Sprintf("too many arguments: %s", strings.Join(rest, " "))}
+	}
+
+	for _, spec := range specs {
+		if _, ok := args.values[spec.Name]; ok {
+			continue
+		}
+		if spec.Required {
+			return nil, &ArgumentError{Message: fmt.Sprintf("%s is required", spec.Name)}
+		}
+		if spec.Default != "" {
+			if err := args.set(spec, spec.Default); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return args, nil
+}
+
+// Usage returns the synopsis of the arguments. e.g. "<env> [--timeout=<duration>]"
+func (specs ArgSpecs) Usage() string {
+	usage := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		var u string
+		switch {
+		case spec.Flag && spec.Type == BoolArg:
+			u = "--" + spec.Name
+		case spec.Flag:
+			u = fmt.Sprintf("--%s=<%s>", spec.Name, spec.Type)
+		case spec.Type == StringArg:
+			u = "<" + spec.Name + ">"
+		default:
+			u = fmt.Sprintf("<%s:%s>", spec.Name, spec.Type)
+		}
+		if spec.Rest {
+			u = strings.TrimSuffix(u, ">") + "...>"
+		}
+		if !spec.Required {
+			u = "[" + u + "]"
+		}
+		usage = append(usage, u)
+	}
+
+	return strings.Join(usage, " ")
+}
+
+func (specs ArgSpecs) flag(name string) (ArgSpec, bool) {
+	for _, spec := range specs {
+		if spec.Flag && spec.Name == name {
+			return spec, true
+		}
+	}
+
+	return ArgSpec{}, false
+}
+
+func (args *Arguments) set(spec ArgSpec, value string) error {
+	var v interface{}
+	switch spec.Type {
+	case StringArg:
+		v = value
+	case IntArg:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return &ArgumentError{Message: fmt.Sprintf("%s must be an integer: %s", spec.Name, value)}
+		}
+		v = i
+	case DurationArg:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return &ArgumentError{Message: fmt.Sprintf("%s must be a duration like 30m: %s", spec.Name, value)}
+		}
+		v = d
+	case BoolArg:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return &ArgumentError{Message: fmt.Sprintf("%s must be true or false: %s", spec.Name, value)}
+		}
+		v = b
+	case UserArg:
+		m := userMentionPattern.FindStringSubmatch(value)
+		if m == nil {
+			return &ArgumentError{Message: fmt.Sprintf("%s must be a user mention: %s", spec.Name, value)}
+		}
+		v = User{Id: m[1], Name: m[2]}
+	case ChannelArg:
+		m := channelMentionPattern.FindStringSubmatch(value)
+		if m == nil {
+			return &ArgumentError{Message: fmt.Sprintf("%s must be a channel mention: %s", spec.Name, value)}
+		}
+		v = ChannelInfo{Id: m[1], Name: m[2]}
+	}
+	args.values[spec.Name] = v
+
+	return nil
+}
+
+// Has reports whether the argument is given or has a default value.
+func (args *Arguments) Has(name string) bool {
+	_, ok := args.values[name]
+	return ok
+}
+
+func (args *Arguments) String(name string) string {
+	v, _ := args.values[name].(string)
+	return v
+}
+
+func (args *Arguments) Int(name string) int {
+	v, _ := args.values[name].(int)
+	return v
+}
+
+func (args *Arguments) Duration(name string) time.Duration {
+	v, _ := args.values[name].(time.Duration)
+	return v
+}
+
+func (args *Arguments) Bool(name string) bool {
+	v, _ := args.values[name].(bool)
+	return v
+}
+
+func (args *Arguments) User(name string) User {
+	v, _ := args.values[name].(User)
+	return v
+}
+
+func (args *Arguments) Channel(name string) ChannelInfo {
+	v, _ := args.values[name].(ChannelInfo)
+	return v
+}
+
+func (t ArgType) String() string {
+	switch t {
+	case IntArg:
+		return "int"
+	case DurationArg:
+		return "duration"
+	case BoolArg:
+		return "bool"
+	case UserArg:
+		return "@user"
+	case ChannelArg:
+		return "#channel"
+	default:
+		return "string"
+	}
+}
+
+// word is the word of the input and its position.
+type word struct {
+	value string
+	// start and end are the byte offsets of the word in the input including the quotes.
+	start, end int
+}
+
+// SplitWords splits text into words like a shell.
+// Words can be quoted with double quotes, single quotes and smart quotes which Slack may convert to.
+// The quote is recognized only at the beginning of the word, so that the apostrophe like "don't" is kept.
+func SplitWords(text string) ([]string, error) {
+	words, err := splitWords(text)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(words))
+	for _, w := range words {
+		res = append(res, w.value)
+	}
+
+	return res, nil
+}
+
+func splitWords(text string) ([]word, error) {
+	words := make([]word, 0)
+	var b strings.Builder
+	inWord := false
+	start := 0
+	var quote rune
+	for i, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote || (quote == '“' && r == '”') || (quote == '‘' && r == '’') {
+				quote = 0
+				continue
+			}
+			b.WriteRune(r)
+		case !inWord && (r == '"' || r == '\'' || r == '“' || r == '‘'):
+			quote = r
+			inWord = true
+			start = i
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word{value: b.String(), start: start, end: i})
+				b.Reset()
+				inWord = false
+			}
+		default:
+			if !inWord {
+				start = i
+			}
+			b.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, &ArgumentError{Message: "unterminated quoted string"}
+	}
+	if inWord {
+		words = append(words, word{value: b.String(), start: start, end: len(text)})
+	}
+
+	return words, nil
+}
+
+// restText returns the input from the first word of rest to the last one as it is,
+// so that the newlines and the spaces are kept. The flags between them are removed.
+// If rest is a single word, the word without the quotes is returned.
+func restText(text string, rest []word, flags []word) string {
+	if len(rest) == 1 {
+		return rest[0].value
+	}
+
+	start, end := rest[0].start, rest[len(rest)-1].end
+	var b strings.Builder
+	pos := start
+	for _, f := range flags {
+		if f.start > start && f.end < end {
+			b.WriteString(text[pos:f.start])
+			pos = f.end
+		}
+	}
+	b.WriteString(text[pos:end])
+
+	return b.String()
+}
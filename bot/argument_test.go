@@ -0,0 +1,181 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitWords(t *testing.T) {
+	cases := []struct {
+		Text  string
+		Words []string
+		Err   bool
+	}{
+		{Text: "", Words: []string{}},
+		{Text: "deploy prod", Words: []string{"deploy", "prod"}},
+		{Text: "  deploy \t prod\n", Words: []string{"deploy", "prod"}},
+		{Text: `say "hello world"`, Words: []string{"say", "hello world"}},
+		{Text: `say 'hello world'`, Words: []string{"say", "hello world"}},
+		{Text: "say “hello world”", Words: []string{"say", "hello world"}},
+		{Text: "say ‘hello world’", Words: []string{"say", "hello world"}},
+		{Text: "don't forget", Words: []string{"don't", "forget"}},
+		{Text: "don’t forget", Words: []string{"don’t", "forget"}},
+		{Text: `--text="a b" x`, Words: []string{`--text="a`, `b"`, "x"}},
+		{Text: `say "hello`, Err: true},
+		{Text: `say 'it`, Err: true},
+	}
+
+	for _, c := range cases {
+		words, err := SplitWords(c.Text)
+		if c.Err {
+			if err == nil {
+				t.Errorf("%q: expected the error", c.Text)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", c.Text, err)
+			continue
+		}
+		if !reflect.DeepEqual(words, c.Words) {
+			t.Errorf("%q: expected %q but got %q", c.Text, c.Words, words)
+		}
+	}
+}
+
+func TestArgSpecs_Parse(t *testing.T) {
+	specs := ArgSpecs{
+		{Name: "env", Required: true},
+		{Name: "count", Type: IntArg, Default: "1"},
+		{Name: "timeout", Type: DurationArg, Flag: true, Default: "5m"},
+		{Name: "force", Type: BoolArg, Flag: true},
+		{Name: "to", Type: UserArg, Flag: true},
+		{Name: "in", Type: ChannelArg, Flag: true},
+	}
+
+	cases := []struct {
+		Text   string
+		Values map[string]interface{}
+		Err    string
+	}{
+		{
+			Text:   "prod",
+			Values: map[string]interface{}{"env": "prod", "count": 1, "timeout": 5 * time.Minute},
+		},
+		{
+			Text:   "prod 3 --timeout=30s --force",
+			Values: map[string]interface{}{"env": "prod", "count": 3, "timeout": 30 * time.Second, "force": true},
+		},
+		{
+			Text:   "--force=false prod",
+			Values: map[string]interface{}{"env": "prod", "count": 1, "timeout": 5 * time.Minute, "force": false},
+		},
+		{
+			Text:   "prod --to=<@U1|alice> --in=<#C1|general>",
+			Values: map[string]interface{}{"env": "prod", "count": 1, "timeout": 5 * time.Minute, "to": User{Id: "U1", Name: "alice"}, "in": ChannelInfo{Id: "C1", Name: "general"}},
+		},
+		{Text: "", Err: "env is required"},
+		{Text: "prod x", Err: "count must be an integer: x"},
+		{Text: "prod 1 2", Err: "too many arguments: 2"},
+		{Text: "prod --unknown", Err: "unknown flag: --unknown"},
+		{Text: "prod --timeout", Err: "flag needs a value: --timeout=<duration>"},
+		{Text: "prod --timeout=soon", Err: "timeout must be a duration like 30m: soon"},
+		{Text: "prod --to=alice", Err: "to must be a user mention: alice"},
+		{Text: `prod "1`, Err: "unterminated quoted string"},
+	}
+
+	for _, c := range cases {
+		args, err := specs.Parse(c.Text)
+		if c.Err != "" {
+			if err == nil || err.Error() != c.Err {
+				t.Errorf("%q: expected the error %q but got %v", c.Text, c.Err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", c.Text, err)
+			continue
+		}
+		if !reflect.DeepEqual(args.values, c.Values) {
+			t.Errorf("%q: expected %v but got %v", c.Text, c.Values, args.values)
+		}
+	}
+}
+
+func TestArgSpecs_ParseRest(t *testing.T) {
+	specs := ArgSpecs{
+		{Name: "channel", Required: true},
+		{Name: "text", Required: true, Rest: true},
+		{Name: "tz", Flag: true},
+	}
+
+	cases := []struct {
+		Text string
+		Rest string
+		Tz   string
+	}{
+		{Text: "general hello", Rest: "hello"},
+		{Text: `general "hello world"`, Rest: "hello world"},
+		{Text: "general don't forget the standup", Rest: "don't forget the standup"},
+		{Text: "general line 1\nline 2", Rest: "line 1\nline 2"},
+		{Text: "general a  b   c", Rest: "a  b   c"},
+		{Text: `general say "hi" to 'them'`, Rest: `say "hi" to 'them'`},
+		{Text: "general hello world --tz=UTC", Rest: "hello world", Tz: "UTC"},
+		{Text: "--tz=UTC general hello world", Rest: "hello world", Tz: "UTC"},
+	}
+
+	for _, c := range cases {
+		args, err := specs.Parse(c.Text)
+		if err != nil {
+			t.Errorf("%q: %s", c.Text, err)
+			continue
+		}
+		if args.String("text") != c.Rest || args.String("tz") != c.Tz {
+			t.Errorf("%q: unexpected text=%q tz=%q", c.Text, args.String("text"), args.String("tz"))
+		}
+	}
+}
+
+func TestArgSpecs_Usage(t *testing.T) {
+	cases := []struct {
+		Specs ArgSpecs
+		Usage string
+	}{
+		{Specs: ArgSpecs{}, Usage: ""},
+		{Specs: ArgSpecs{{Name: "env", Required: true}}, Usage: "<env>"},
+		{Specs: ArgSpecs{{Name: "count", Type: IntArg}}, Usage: "[<count:int>]"},
+		{Specs: ArgSpecs{{Name: "text", Required: true, Rest: true}}, Usage: "<text...>"},
+		{Specs: ArgSpecs{{Name: "force", Type: BoolArg, Flag: true}}, Usage: "[--force]"},
+		{Specs: ArgSpecs{{Name: "timeout", Type: DurationArg, Flag: true}}, Usage: "[--timeout=<duration>]"},
+		{
+			Specs: ArgSpecs{
+				{Name: "env", Required: true},
+				{Name: "to", Type: UserArg, Required: true},
+				{Name: "in", Type: ChannelArg, Flag: true},
+			},
+			Usage: "<env> <to:@user> [--in=<#channel>]",
+		},
+	}
+
+	for _, c := range cases {
+		if usage := c.Specs.Usage(); usage != c.Usage {
+			t.Errorf("expected %q but got %q", c.Usage, usage)
+		}
+	}
+}
+
+func TestCommand_MultiLineArgs(t *testing.T) {
+	b, connector, stop := startTestBot()
+	defer stop()
+	b.CommandWithArgv("echo", "", func(event *Event) {
+		event.Say(event.Args.String("text"))
+	}, Args(ArgSpec{Name: "text", Required: true, Rest: true}))
+	b.Group("note", "").Command("add", "", func(event *Event) {
+		event.Say(event.Args.String("text"))
+	}, Args(ArgSpec{Name: "text", Required: true, Rest: true}))
+
+	transcript := connector.Transcript(t, "C1")
+	transcript.Expect("U1", "bot echo first line\nsecond  line").Reply("first line\nsecond  line")
+	transcript.Expect("U1", "bot note add first line\nsecond  line").Reply("first line\nsecond  line")
+}
@@ -30,7 +30,11 @@ type Bot struct {
 
 	connectErrorChan  chan error
 	eventHandler      *EventHandler
+	roles             *RoleManager
+	dialogs           *DialogManager
+	interactions      *InteractionManager
 	scheduler         *Scheduler
+	clock             Clock
 	connectRetryCount int
 	disconnectCount   int
 	ctx               context.Context
@@ -41,16 +45,43 @@ func NewBot(connector Connector, persistence Persistence, name string, ignoreUse
 	if persistence == nil {
 		persistence = &NoneDB{}
 	}
-	return &Bot{
+	roles := NewRoleManager(persistence, connector)
+	eventHandler := NewEventHandler(ignoreUsers, acceptUsers)
+	eventHandler.roles = roles
+
+	bot := &Bot{
 		Connector:         connector,
 		Name:              name,
 		Persistence:       persistence,
 		connectErrorChan:  make(chan error),
-		eventHandler:      NewEventHandler(ignoreUsers, acceptUsers),
+		eventHandler:      eventHandler,
+		roles:             roles,
 		scheduler:         NewScheduler(),
+		clock:             SystemClock,
 		connectRetryCount: 0,
 		disconnectCount:   0,
 	}
+	bot.dialogs = NewDialogManager(bot)
+	eventHandler.dialogs = bot.dialogs
+	bot.interactions = NewInteractionManager(bot)
+	eventHandler.interactions = bot.interactions
+	bot.scheduler.persistence = persistence
+
+	return bot
+}
+
+// SetClock replaces the clock of the bot, the scheduler, the event handler and the roles.
+// It must be called before registering any command or schedule.
+func (bot *Bot) SetClock(clock Clock) {
+	bot.clock = clock
+	bot.scheduler.SetClock(clock)
+	bot.eventHandler.clock = clock
+	bot.roles.clock = clock
+}
+
+// Clock returns the clock of the bot.
+func (bot *Bot) Clock() Clock {
+	return bot.clock
 }
 
 func (bot *Bot) Start(ctx context.Context) error {
@@ -58,6 +89,15 @@ func (bot *Bot) Start(ctx context.Context) error {
 	bot.ctx = c
 	bot.cancel = cancel
 
+	if err := bot.dialogs.Restore(); err != nil {
+		log.Printf("failed to restore dialogs: %s", err)
+	}
+	if err := bot.interactions.Restore(); err != nil {
+		log.Printf("failed to restore interactions: %s", err)
+	}
+	if err := bot.scheduler.Restore(); err != nil {
+		log.Printf("failed to restore schedules: %s", err)
+	}
 	go bot.scheduler.Start(bot.ctx)
 
 	for {
@@ -92,10 +132,11 @@ func (bot *Bot) Start(ctx context.Context) error {
 			case entry := <-bot.scheduler.TriggeredEvent():
 				e := entry.ToEvent()
 				e.Bot = bot
+				middlewares := bot.eventHandler.Middlewares()
 				if bot.Connector.Async() {
-					go entry.Execute(e)
+					go entry.Execute(e, middlewares...)
 				} else {
-					entry.Execute(e)
+					entry.Execute(e, middlewares...)
 					bot.Connector.Idle() <- true
 				}
 			case err := <-bot.connectErrorChan:
@@ -138,40 +179,156 @@ func (bot *Bot) OnError(f OnError) {
 	bot.eventHandler.OnError = f
 }
 
-func (bot *Bot) Send(event *Event, text string) {
-	bot.Connector.Send(event, bot.Name, text)
+// Use adds the middleware which is applied to every message, reaction, typing and scheduled callback.
+// Middlewares are applied in the order in which they were added.
+func (bot *Bot) Use(middleware Middleware) {
+	bot.eventHandler.Use(middleware)
+}
+
+// Roles returns RoleManager for declaring roles and assigning users to them.
+func (bot *Bot) Roles() *RoleManager {
+	return bot.roles
+}
+
+// RegisterDialog registers the dialog which can be started by StartDialog.
+func (bot *Bot) RegisterDialog(dialog *Dialog) {
+	bot.dialogs.Register(dialog)
+}
+
+func (bot *Bot) StartDialog(event *Event, name string) error {
+	return bot.dialogs.Start(event, name)
+}
+
+// RegisterInteraction registers the interaction which handles the actions on the message sent by SendInteractive.
+func (bot *Bot) RegisterInteraction(interaction *Interaction) {
+	bot.interactions.Register(interaction)
+}
+
+// SendInteractive posts the message and routes the actions on it to the registered interaction.
+// data is stored with the message and is passed to the interaction.
+func (bot *Bot) SendInteractive(event *Event, name string, message *RichMessage, data map[string]string) (*InteractiveMessage, error) {
+	return bot.interactions.Send(event, name, message, data)
+}
+
+// Send posts the text to the channel of the event and returns the posted message.
+func (bot *Bot) Send(event *Event, text string) (*SentMessage, error) {
+	if event.inThread {
+		return bot.SendInThread(event, text)
+	}
+
+	return bot.Connector.Send(event, bot.Name, text)
+}
+
+func (bot *Bot) SendInThread(event *Event, text string) (*SentMessage, error) {
+	return bot.Connector.SendInThread(event, bot.Name, event.ThreadRoot(), text)
+}
+
+// SendRich posts the message which has the layout.
+// If the event is in the thread, the message is posted to the thread.
+func (bot *Bot) SendRich(event *Event, message *RichMessage) (*SentMessage, error) {
+	threadTs := ""
+	if event.inThread {
+		threadTs = event.ThreadRoot()
+	}
+
+	return bot.Connector.SendRich(event, bot.Name, threadTs, message)
+}
+
+// SendWithActions posts the message and calls callback when the user operates the button or the menu of it.
+// The callback is kept only in memory. If the message should survive a restart, use SendInteractive.
+func (bot *Bot) SendWithActions(event *Event, message *RichMessage, callback func(*Event)) (*SentMessage, error) {
+	sent, err := bot.SendRich(event, message)
+	if err != nil {
+		return nil, err
+	}
+	bot.eventHandler.RequireAction(sent.Channel, sent.Timestamp, callback)
+
+	return sent, nil
 }
 
-func (bot *Bot) Sendf(event *Event, format string, a ...interface{}) {
+func (bot *Bot) Sendf(event *Event, format string, a ...interface{}) (*SentMessage, error) {
 	text := fmt.Sprintf(format, a...)
-	bot.Send(event, text)
+	return bot.Send(event, text)
 }
 
-func (bot *Bot) SendWithConfirm(event *Event, text, reaction string, callback func(*Event)) {
-	id, _ := bot.Connector.SendWithConfirm(event, bot.Name, text)
-	bot.eventHandler.RequireReaction(event.Channel, id, reaction, event.User.Id, callback)
+// SendWithConfirm posts the text and calls callback when the user of the event adds the reaction to it.
+// If the text is not posted, callback is never called.
+func (bot *Bot) SendWithConfirm(event *Event, text, reaction string, callback func(*Event)) (*SentMessage, error) {
+	sent, err := bot.sendWithConfirm(event, text)
+	if err != nil {
+		return nil, err
+	}
+	bot.eventHandler.RequireReaction(event.Channel, sent.Timestamp, reaction, event.User.Id, callback)
+
+	return sent, nil
 }
 
-func (bot *Bot) SendAndRequestReactFromOther(event *Event, text, reaction string, callback func(*Event)) {
-	id, _ := bot.Connector.SendWithConfirm(event, bot.Name, text)
-	bot.eventHandler.RequireReactionByOther(event.Channel, id, reaction, event.User.Id, callback)
+func (bot *Bot) SendAndRequestReactFromOther(event *Event, text, reaction string, callback func(*Event)) (*SentMessage, error) {
+	sent, err := bot.sendWithConfirm(event, text)
+	if err != nil {
+		return nil, err
+	}
+	bot.eventHandler.RequireReactionByOther(event.Channel, sent.Timestamp, reaction, event.User.Id, callback)
+
+	return sent, nil
 }
 
-func (bot *Bot) SendWithConfirmf(event *Event, reaction string, callback func(*Event), format string, a ...interface{}) {
-	text := fmt.Sprintf(format, a)
-	bot.SendWithConfirm(event, text, reaction, callback)
+func (bot *Bot) SendWithConfirmf(event *Event, reaction string, callback func(*Event), format string, a ...interface{}) (*SentMessage, error) {
+	text := fmt.Sprintf(format, a...)
+	return bot.SendWithConfirm(event, text, reaction, callback)
 }
 
-func (bot *Bot) SendRequireResponse(event *Event, text string) (func(), chan string) {
-	bot.Connector.Send(event, bot.Name, text)
-	return bot.eventHandler.RequireResponse(event.Channel, event.User.Id)
+// SendRequireResponse sends the text and waits for the response from the user.
+// If the event is in a thread, only the response in the thread is accepted.
+// If the text is not sent, the response is not waited for and the error is returned.
+func (bot *Bot) SendRequireResponse(event *Event, text string) (func(), chan string, error) {
+	if event.inThread || event.ThreadTs != "" {
+		if _, err := bot.SendInThread(event, text); err != nil {
+			return nil, nil, err
+		}
+		cancel, response := bot.eventHandler.RequireResponse(event.Channel, event.User.Id, event.ThreadRoot())
+		return cancel, response, nil
+	}
+
+	if _, err := bot.Connector.Send(event, bot.Name, text); err != nil {
+		return nil, nil, err
+	}
+	cancel, response := bot.eventHandler.RequireResponse(event.Channel, event.User.Id, "")
+	return cancel, response, nil
 }
 
-func (bot *Bot) SendRequireResponsef(event *Event, format string, a ...interface{}) (func(), chan string) {
-	text := fmt.Sprintf(format, a)
+func (bot *Bot) SendRequireResponsef(event *Event, format string, a ...interface{}) (func(), chan string, error) {
+	text := fmt.Sprintf(format, a...)
 	return bot.SendRequireResponse(event, text)
 }
 
+func (bot *Bot) sendWithConfirm(event *Event, text string) (*SentMessage, error) {
+	if event.inThread {
+		return bot.SendInThread(event, text)
+	}
+
+	ts, err := bot.Connector.SendWithConfirm(event, bot.Name, text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SentMessage{Message: text, Channel: event.Channel, Timestamp: ts}, nil
+}
+
+// UpdateMessage replaces the text of the message which has been sent by the bot.
+func (bot *Bot) UpdateMessage(message *SentMessage, text string) (*SentMessage, error) {
+	return bot.Connector.UpdateMessage(message, text)
+}
+
+// UpdateRich replaces the message which has been sent with the rich message.
+func (bot *Bot) UpdateRich(message *SentMessage, rich *RichMessage) (*SentMessage, error) {
+	return bot.Connector.UpdateRich(message, rich)
+}
+
+func (bot *Bot) DeleteMessage(message *SentMessage) error {
+	return bot.Connector.DeleteMessage(message)
+}
+
 func (bot *Bot) WithIndicate(channel string, f func() error) {
 	cancel := bot.Connector.WithIndicate(channel)
 	defer cancel()
@@ -182,32 +339,74 @@ func (bot *Bot) Attach(event *Event, title, fileName string, file io.Reader) err
 	return bot.Connector.Attach(event, fileName, file, title)
 }
 
-func (bot *Bot) SendPrivate(event *Event, text string) {
-	bot.Connector.SendPrivate(event, event.User.Id, text)
+// SendPrivate sends the text to the user of the event as the direct message.
+func (bot *Bot) SendPrivate(event *Event, text string) (*SentMessage, error) {
+	return bot.Connector.SendPrivate(event, event.User.Id, text)
+}
+
+// SendEphemeral sends the text which is shown only to the user of the event in the channel.
+func (bot *Bot) SendEphemeral(event *Event, text string) error {
+	return bot.Connector.SendEphemeral(event, event.User.Id, text)
 }
 
 func (bot *Bot) GetPermalink(event *Event) string {
 	return bot.Connector.GetPermalink(event)
 }
 
-func (bot *Bot) Hear(pattern string, callback func(*Event)) {
-	bot.eventHandler.AddCommand(regexp.MustCompile(pattern), "", callback, false)
+func (bot *Bot) Hear(pattern string, callback func(*Event), opts ...CommandOption) {
+	bot.eventHandler.AddCommand(regexp.MustCompile(pattern), "", callback, false, opts...)
+}
+
+func (bot *Bot) Command(pattern string, description string, callback func(*Event), opts ...CommandOption) {
+	opts = append(opts, commandName(pattern))
+	bot.eventHandler.AddCommand(regexp.MustCompile("\\A"+bot.Name+"\\s+"+pattern+"\\z"), describe(pattern, description, opts), callback, false, opts...)
+}
+
+func (bot *Bot) CommandWithArgv(pattern string, description string, callback func(*Event), opts ...CommandOption) {
+	opts = append(opts, commandName(pattern))
+	bot.eventHandler.AddCommand(regexp.MustCompile("\\A"+bot.Name+"\\s+"+pattern+"(?:\\s+(?s:(.+)))*\\z"), describe(pattern, description, opts), callback, true, opts...)
+}
+
+// SlashCommand registers the handler of the slash command like "/deploy".
+// The arguments are parsed by Args as well as Command.
+func (bot *Bot) SlashCommand(name, description string, callback func(*Event), opts ...CommandOption) {
+	bot.eventHandler.AddSlashCommand(name, describe(slashCommandName(name), description, opts), callback, opts...)
+}
+
+// Group registers the command which has subcommands.
+// The options are applied to all subcommands in the group.
+func (bot *Bot) Group(name, description string, opts ...CommandOption) *CommandGroup {
+	group := newCommandGroup(name, description, opts)
+	// The options are also given to the command of the group itself, e.g. for RerunOnEdit.
+	groupOpts := append(append(make([]CommandOption, 0, len(opts)+1), opts...), func(command *Command) {
+		command.group = group
+	})
+	bot.eventHandler.AddCommand(regexp.MustCompile("\\A"+bot.Name+"\\s+"+name+"(?:\\s+(?s:(.+)))*\\z"), group.summary(), nil, true, groupOpts...)
+
+	return group
 }
 
-func (bot *Bot) Command(pattern string, description string, callback func(*Event)) {
-	desc := ""
-	if description != "" {
-		desc = pattern + " - " + description
+func commandName(name string) CommandOption {
+	return func(command *Command) {
+		command.name = name
 	}
-	bot.eventHandler.AddCommand(regexp.MustCompile("\\A"+bot.Name+"\\s+"+pattern+"\\z"), desc, callback, false)
 }
 
-func (bot *Bot) CommandWithArgv(pattern string, description string, callback func(*Event)) {
-	desc := ""
-	if description != "" {
-		desc = pattern + " - " + description
+// describe returns the line of the command for Help.
+func describe(pattern, description string, opts []CommandOption) string {
+	if description == "" {
+		return ""
 	}
-	bot.eventHandler.AddCommand(regexp.MustCompile("\\A"+bot.Name+"\\s+"+pattern+"(?:\\s+(.+))*\\z"), desc, callback, true)
+
+	command := &Command{}
+	for _, opt := range opts {
+		opt(command)
+	}
+	if len(command.args) > 0 {
+		return pattern + " " + command.args.Usage() + " - " + description
+	}
+
+	return pattern + " - " + description
 }
 
 func (bot *Bot) Appearance(user string, callback func(*Event)) {
@@ -218,20 +417,65 @@ func (bot *Bot) WatchReaction(reaction string, callback func(*Event)) {
 	bot.eventHandler.WatchReaction(reaction, callback)
 }
 
-func (bot *Bot) Every(interval time.Duration, channel string, callback ScheduleFunc) {
-	if err := bot.scheduler.Every(interval, channel, callback); err != nil {
+// OnMessageChanged calls callback when the user edits the message.
+// The commands which have RerunOnEdit are matched with the edited message besides.
+func (bot *Bot) OnMessageChanged(callback func(*Event)) {
+	bot.eventHandler.AddHandler(MessageChangedEvent, &Command{callback: callback})
+}
+
+// OnMessageDeleted calls callback when the message of the user is deleted.
+func (bot *Bot) OnMessageDeleted(callback func(*Event)) {
+	bot.eventHandler.AddHandler(MessageDeletedEvent, &Command{callback: callback})
+}
+
+func (bot *Bot) WatchReactionRemoved(reaction string, callback func(*Event)) {
+	bot.eventHandler.WatchReactionRemoved(reaction, callback)
+}
+
+// OnMemberJoined calls callback when the user joins the channel. If channel is empty, any channel is watched.
+func (bot *Bot) OnMemberJoined(channel string, callback func(*Event)) {
+	bot.eventHandler.MemberJoined(channel, callback)
+}
+
+// OnChannelCreated calls callback when the channel is created. The user of the event is the creator.
+func (bot *Bot) OnChannelCreated(callback func(*Event)) {
+	bot.eventHandler.AddHandler(ChannelCreatedEvent, &Command{callback: callback})
+}
+
+func (bot *Bot) Every(interval time.Duration, channel string, callback ScheduleFunc, opts ...ScheduleOption) {
+	if err := bot.scheduler.Every(interval, channel, callback, opts...); err != nil {
 		panic(err)
 	}
 }
 
-func (bot *Bot) At(every UnitTime, hour, minute int, channel string, callback ScheduleFunc) {
-	if err := bot.scheduler.At(every, hour, minute, channel, callback); err != nil {
+func (bot *Bot) At(every UnitTime, hour, minute int, channel string, callback ScheduleFunc, opts ...ScheduleOption) {
+	if err := bot.scheduler.At(every, hour, minute, channel, callback, opts...); err != nil {
+		panic(err)
+	}
+}
+
+// Scheduler returns Scheduler for managing the scheduled entries at runtime.
+func (bot *Bot) Scheduler() *Scheduler {
+	return bot.scheduler
+}
+
+// Once registers the callback which is executed only once at the time.
+func (bot *Bot) Once(at time.Time, channel string, callback ScheduleFunc, opts ...ScheduleOption) string {
+	return bot.scheduler.Once(at, channel, callback, opts...)
+}
+
+// Cron registers the scheduled callback by the 5-field cron expression which is evaluated in tz.
+// If tz is nil, the Local time zone is used.
+func (bot *Bot) Cron(spec string, tz *time.Location, channel string, callback ScheduleFunc, opts ...ScheduleOption) {
+	if err := bot.scheduler.Cron(spec, tz, channel, callback, opts...); err != nil {
 		panic(err)
 	}
 }
 
 func (bot *Bot) Help() string {
-	commands := bot.eventHandler.commands[MessageEvent]
+	commands := make([]Command, 0)
+	commands = append(commands, bot.eventHandler.commands[MessageEvent]...)
+	commands = append(commands, bot.eventHandler.commands[SlashCommandEvent]...)
 	descriptions := make([]string, 0, len(commands))
 	for _, command := range commands {
 		// CommandTypeRequireResponse is a temporary event.
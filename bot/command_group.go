@@ -0,0 +1,212 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	helpSubcommand = "help"
+)
+
+var (
+	firstWordPattern = regexp.MustCompile(`\A\s*(\S+)\s*`)
+)
+
+// CommandGroup is the command which has nested subcommands like "deploy start <env>".
+// The subcommand is routed by the word, and "help" prints the help of the group.
+type CommandGroup struct {
+	name        string
+	description string
+	opts        []CommandOption
+	commands    map[string]*Command
+	groups      map[string]*CommandGroup
+	mutex       sync.RWMutex
+}
+
+func newCommandGroup(name, description string, opts []CommandOption) *CommandGroup {
+	return &CommandGroup{
+		name:        name,
+		description: description,
+		opts:        opts,
+		commands:    make(map[string]*Command),
+		groups:      make(map[string]*CommandGroup),
+	}
+}
+
+// Command registers the subcommand.
+// The options of the group are applied before opts.
+func (group *CommandGroup) Command(name, description string, callback func(*Event), opts ...CommandOption) {
+	fullName := group.name + " " + name
+	options := append(append(append([]CommandOption{}, group.opts...), opts...), commandName(fullName))
+	command := &Command{description: describe(fullName, description, options), callback: callback, argv: true}
+	for _, opt := range options {
+		opt(command)
+	}
+
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	group.commands[name] = command
+}
+
+// Group registers the nested group.
+func (group *CommandGroup) Group(name, description string, opts ...CommandOption) *CommandGroup {
+	g := newCommandGroup(group.name+" "+name, description, append(append([]CommandOption{}, group.opts...), opts...))
+
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	group.groups[name] = g
+	return g
+}
+
+// Help returns the descriptions of the subcommands.
+func (group *CommandGroup) Help() string {
+	group.mutex.RLock()
+	defer group.mutex.RUnlock()
+
+	descriptions := make([]string, 0, len(group.commands)+len(group.groups))
+	for _, command := range group.commands {
+		if command.description == "" {
+			continue
+		}
+		descriptions = append(descriptions, command.description)
+	}
+	for _, g := range group.groups {
+		descriptions = append(descriptions, g.summary())
+	}
+	sort.Strings(descriptions)
+
+	return strings.Join(descriptions, "\n")
+}
+
+// command returns the command which has the options of the group for authorizing the group itself.
+func (group *CommandGroup) command() Command {
+	command := Command{name: group.name}
+	for _, opt := range group.opts {
+		opt(&command)
+	}
+
+	return command
+}
+
+func (group *CommandGroup) summary() string {
+	return group.name + " <subcommand> - " + group.description
+}
+
+// route finds the subcommand from text and returns it with the rest of text.
+// If the subcommand is not found, the help or the suggestion is sent and route returns false.
+// The group is authorized before routing, so that the user who can't run the group doesn't see its subcommands.
+func (group *CommandGroup) route(event *Event, text string, authorize func(Command, *Event) bool) (Command, string, bool) {
+	if !authorize(group.command(), event) {
+		return Command{}, "", false
+	}
+
+	name, rest := "", ""
+	if m := firstWordPattern.FindStringSubmatchIndex(text); m != nil {
+		name, rest = text[m[2]:m[3]], text[m[1]:]
+	}
+
+	group.mutex.RLock()
+	command, commandOk := group.commands[name]
+	sub, groupOk := group.groups[name]
+	group.mutex.RUnlock()
+
+	switch {
+	case commandOk:
+		return *command, rest, true
+	case groupOk:
+		return sub.route(event, rest, authorize)
+	case name == "" || name == helpSubcommand:
+		event.Say(group.Help())
+	default:
+		msg := fmt.Sprintf("unknown subcommand: %s %s", group.name, name)
+		if s := group.suggest(name); s != "" {
+			msg += fmt.Sprintf("\ndid you mean `%s %s`?", group.name, s)
+		} else {
+			msg += fmt.Sprintf("\nsee `%s %s %s`", event.Bot.Name, group.name, helpSubcommand)
+		}
+		event.Whisper(msg)
+	}
+
+	return Command{}, "", false
+}
+
+// lookup reports whether the text routes to any subcommand. Unlike route, it doesn't reply to the user.
+func (group *CommandGroup) lookup(text string) bool {
+	name, rest := "", ""
+	if m := firstWordPattern.FindStringSubmatchIndex(text); m != nil {
+		name, rest = text[m[2]:m[3]], text[m[1]:]
+	}
+
+	group.mutex.RLock()
+	_, commandOk := group.commands[name]
+	sub, groupOk := group.groups[name]
+	group.mutex.RUnlock()
+
+	switch {
+	case commandOk:
+		return true
+	case groupOk:
+		return sub.lookup(rest)
+	}
+
+	return false
+}
+
+// suggest returns the closest name of the subcommand.
+// If there is no name close enough, suggest returns an empty string.
+func (group *CommandGroup) suggest(name string) string {
+	group.mutex.RLock()
+	defer group.mutex.RUnlock()
+
+	candidates := make([]string, 0, len(group.commands)+len(group.groups))
+	for n := range group.commands {
+		candidates = append(candidates, n)
+	}
+	for n := range group.groups {
+		candidates = append(candidates, n)
+	}
+	sort.Strings(candidates)
+
+	suggestion := ""
+	min := len(name)/2 + 1
+	for _, c := range candidates {
+		if d := levenshtein(name, c); d < min {
+			suggestion, min = c, d
+		}
+	}
+
+	return suggestion
+}
+
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(t)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
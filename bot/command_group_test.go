@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"testing"
+)
+
+func TestCommandGroup(t *testing.T) {
+	b, connector, stop := startTestBot()
+	defer stop()
+	deploy := b.Group("deploy", "deploy the application")
+	deploy.Command("start", "start the deploy", func(event *Event) {
+		event.Sayf("deploying %s", event.Args.String("env"))
+	}, Args(ArgSpec{Name: "env", Required: true}))
+	deploy.Group("config", "manage the config").Command("show", "show the config", func(event *Event) {
+		event.Say("config")
+	})
+
+	transcript := connector.Transcript(t, "C1")
+	transcript.Expect("U1", "bot deploy start prod").Reply("deploying prod")
+	transcript.Expect("U1", "bot deploy config show").Reply("config")
+	transcript.Expect("U1", "bot deploy help").Reply("deploy config <subcommand> - manage the config\ndeploy start <env> - start the deploy")
+	transcript.Expect("U1", "bot deploy strat prod").Reply("unknown subcommand: deploy strat\ndid you mean `deploy start`?")
+	transcript.Expect("U1", "bot deploy start").Reply("env is required\nusage: deploy start <env>")
+}
+
+func TestCommandGroup_RequireRole(t *testing.T) {
+	b, connector := newTestBot()
+	if err := b.Roles().DefineRole("admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Roles().AddUser("admin", "U1"); err != nil {
+		t.Fatal(err)
+	}
+	stop := runTestBot(b)
+	defer stop()
+	admin := b.Group("admin", "administrate the bot", RequireRole("admin"))
+	admin.Command("restart", "restart the bot", func(event *Event) {
+		event.Say("restarting")
+	})
+	admin.Group("user", "manage users").Command("list", "list users", func(event *Event) {
+		event.Say("U1")
+	})
+
+	transcript := connector.Transcript(t, "C1")
+	transcript.Expect("U1", "bot admin restart").Reply("restarting")
+	transcript.Expect("U1", "bot admin user list").Reply("U1")
+
+	// The help and the suggestion are not shown to the user who doesn't have the role.
+	denied := "you are not allowed to run this command"
+	transcript.Expect("U2", "bot admin restart").Reply(denied)
+	transcript.Expect("U2", "bot admin help").Reply(denied)
+	transcript.Expect("U2", "bot admin").Reply(denied)
+	transcript.Expect("U2", "bot admin restrat").Reply(denied)
+	transcript.Expect("U2", "bot admin user help").Reply(denied)
+	transcript.Expect("U2", "bot admin user list").Reply(denied)
+}
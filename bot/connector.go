@@ -10,11 +10,18 @@ type Connector interface {
 	Connect() error
 	Listen() error
 	ReceivedEvent() chan *Event
-	Send(*Event, string, string) error
+	Send(*Event, string, string) (*SentMessage, error)
 	SendWithConfirm(*Event, string, string) (string, error)
+	SendInThread(*Event, string, string, string) (*SentMessage, error)
+	SendRich(*Event, string, string, *RichMessage) (*SentMessage, error)
 	Attach(*Event, string, io.Reader, string) error
 	WithIndicate(string) context.CancelFunc
-	SendPrivate(*Event, string, string) error
+	SendPrivate(*Event, string, string) (*SentMessage, error)
+	SendEphemeral(*Event, string, string) error
+	UpdateMessage(*SentMessage, string) (*SentMessage, error)
+	UpdateRich(*SentMessage, *RichMessage) (*SentMessage, error)
+	DeleteMessage(*SentMessage) error
+	RespondSlashCommand(*Event, string, bool) error
 	Async() bool
 	Idle() chan bool
 	GetChannelInfo(string) (*ChannelInfo, error)
@@ -26,9 +33,20 @@ const (
 	UserTypingEvent    = "user_typing"
 	ReactionAddedEvent = "reaction_added"
 	ScheduledEvent     = "scheduled"
-	UnknownEvent       = "unknown"
+	InteractionEvent   = "interaction"
+	SlashCommandEvent  = "slash_command"
+	// MessageChangedEvent has the new text in Message and the old text in PreviousMessage.
+	MessageChangedEvent = "message_changed"
+	// MessageDeletedEvent has the text of the deleted message in PreviousMessage.
+	MessageDeletedEvent      = "message_deleted"
+	ReactionRemovedEvent     = "reaction_removed"
+	MemberJoinedChannelEvent = "member_joined_channel"
+	ChannelCreatedEvent      = "channel_created"
+	UnknownEvent             = "unknown"
 )
 
+// SentMessage is the message which is posted by the bot.
+// Timestamp is the id of the message in Channel.
 type SentMessage struct {
 	Message   string
 	Channel   string
@@ -36,8 +54,14 @@ type SentMessage struct {
 }
 
 type User struct {
-	Id   string
-	Name string
+	Id          string
+	Name        string
+	RealName    string
+	DisplayName string
+	// TimeZone is the name of the time zone like "Asia/Tokyo".
+	TimeZone  string
+	IsBot     bool
+	IsDeleted bool
 }
 
 func (user User) Format(f fmt.State, c rune) {
@@ -48,6 +72,13 @@ func (user User) Format(f fmt.State, c rune) {
 	fmt.Fprint(f, user.Name)
 }
 
+// Action is the component which is operated by the user in InteractionEvent.
+type Action struct {
+	Id      string
+	BlockId string
+	Value   string
+}
+
 type ChannelInfo struct {
 	Id   string `json:"id"`
 	Name string `json:"name"`
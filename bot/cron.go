@@ -0,0 +1,279 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	monthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	weekdayNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+	cronShortcuts = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// Schedule calculates the next activation time of ScheduleEntry.
+type Schedule interface {
+	Next(time.Time) time.Time
+}
+
+// CronSchedule is the schedule which is described by the standard 5-field cron expression.
+//
+// The fields are minute, hour, day of month, month and day of week.
+// Each field accepts "*", lists ("1,3"), ranges ("1-5") and steps ("*/15", "0-30/10").
+// Month and day of week accept names ("JAN", "MON").
+// Day of week also accepts "weekday#n" for the n-th weekday of the month, e.g. "MON#1" is the first Monday.
+// When both of day of month and day of week are restricted, the entry runs if either matches.
+//
+// The expression is evaluated on the wall clock of the location.
+// If the time is skipped by DST, the entry runs at the first minute after the gap.
+// If the time is repeated by DST, the entry runs only once unless the hour field is "*".
+type CronSchedule struct {
+	minute   uint64
+	hour     uint64
+	dom      uint64
+	month    uint64
+	dow      uint64
+	nth      map[int]uint8
+	domStar  bool
+	dowStar  bool
+	hourStar bool
+	loc      *time.Location
+}
+
+// ParseCron parses the cron expression.
+// If loc is nil, the expression is evaluated in the Local time zone.
+// The expression which never matches, e.g. "0 0 30 2 *", is an error.
+func ParseCron(spec string, loc *time.Location) (*CronSchedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	if s, ok := cronShortcuts[strings.ToLower(strings.TrimSpace(spec))]; ok {
+		spec = s
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron: expected 5 fields but got %d: %s", len(fields), spec)
+	}
+
+	schedule := &CronSchedule{loc: loc, nth: make(map[int]uint8)}
+	var err error
+	if schedule.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if schedule.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if schedule.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if schedule.month, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, err
+	}
+	if err := schedule.parseWeekday(fields[4]); err != nil {
+		return nil, err
+	}
+	schedule.hourStar = fields[1] == "*"
+	schedule.domStar = fields[2] == "*" || fields[2] == "?"
+	schedule.dowStar = fields[4] == "*" || fields[4] == "?"
+	if schedule.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron: never activated: %s", spec)
+	}
+
+	return schedule, nil
+}
+
+// Next returns the first activation time after t.
+// It returns the zero time if no time matches within 5 years.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	origin := t.In(s.loc)
+	t = origin.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			next := t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			if s.skipped(t, next) {
+				return next
+			}
+			t = next
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			next := t.Add(time.Minute)
+			if s.skipped(t, next) {
+				return next
+			}
+			t = next
+			continue
+		}
+		if !s.hourStar && !wallClockAfter(t, origin) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *CronSchedule) matchDay(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	// nth holds the set of the week numbers of the weekday as the bitmask.
+	if s.nth[int(t.Weekday())]&(1<<uint((t.Day()-1)/7+1)) != 0 {
+		dow = true
+	}
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// skipped reports whether any hour which is jumped over by DST between from and to matches.
+func (s *CronSchedule) skipped(from, to time.Time) bool {
+	if from.Day() != to.Day() {
+		return false
+	}
+	for h := from.Hour() + 1; h < to.Hour(); h++ {
+		if has(s.hour, h) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *CronSchedule) parseWeekday(field string) error {
+	rest := make([]string, 0)
+	for _, f := range strings.Split(field, ",") {
+		i := strings.Index(f, "#")
+		if i < 0 {
+			rest = append(rest, f)
+			continue
+		}
+
+		d, err := parseCronValue(f[:i], weekdayNames)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(f[i+1:])
+		if err != nil || n < 1 || n > 5 {
+			return fmt.Errorf("cron: invalid week number: %s", f)
+		}
+		s.nth[d%7] |= 1 << uint(n)
+	}
+	if len(rest) == 0 {
+		return nil
+	}
+
+	dow, err := parseCronField(strings.Join(rest, ","), 0, 7, weekdayNames)
+	if err != nil {
+		return err
+	}
+	// Both of 0 and 7 mean Sunday.
+	if has(dow, 7) {
+		dow |= 1
+	}
+	s.dow = dow
+
+	return nil
+}
+
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return 0, fmt.Errorf("cron: invalid step: %s", part)
+			}
+			step = s
+			part = part[:i]
+		}
+
+		start, end := min, max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			r := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = parseCronValue(r[0], names); err != nil {
+				return 0, err
+			}
+			if end, err = parseCronValue(r[1], names); err != nil {
+				return 0, err
+			}
+		default:
+			v, err := parseCronValue(part, names)
+			if err != nil {
+				return 0, err
+			}
+			start = v
+			if step == 1 {
+				end = v
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("cron: out of range: %s", part)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseCronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("cron: invalid value: %s", s)
+	}
+
+	return v, nil
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+func wallClockAfter(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	if ay != by || am != bm || ad != bd {
+		return a.After(b)
+	}
+
+	return a.Hour()*60+a.Minute() > b.Hour()*60+b.Minute()
+}
@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronSchedule_Next(t *testing.T) {
+	cases := []struct {
+		Spec string
+		From string
+		Next string
+	}{
+		{Spec: "*/15 * * * *", From: "2024-01-01T00:07:00Z", Next: "2024-01-01T00:15:00Z"},
+		{Spec: "0-30/10 1 * * *", From: "2024-01-01T01:05:00Z", Next: "2024-01-01T01:10:00Z"},
+		{Spec: "0-30/10 1 * * *", From: "2024-01-01T01:30:00Z", Next: "2024-01-02T01:00:00Z"},
+		{Spec: "5,45 * * * *", From: "2024-01-01T00:05:00Z", Next: "2024-01-01T00:45:00Z"},
+		{Spec: "0 9 * * MON-FRI", From: "2024-01-06T10:00:00Z", Next: "2024-01-08T09:00:00Z"},
+		{Spec: "0 0 1 JAN,jul *", From: "2024-02-01T00:00:00Z", Next: "2024-07-01T00:00:00Z"},
+		{Spec: "30 8 * * 0", From: "2024-01-01T00:00:00Z", Next: "2024-01-07T08:30:00Z"},
+		{Spec: "30 8 * * 7", From: "2024-01-01T00:00:00Z", Next: "2024-01-07T08:30:00Z"},
+		{Spec: "30 8 * * SUN", From: "2024-01-01T00:00:00Z", Next: "2024-01-07T08:30:00Z"},
+		{Spec: "0 0 13 * FRI", From: "2024-01-01T00:00:00Z", Next: "2024-01-05T00:00:00Z"},
+		{Spec: "0 10 * * MON#1", From: "2024-01-02T00:00:00Z", Next: "2024-02-05T10:00:00Z"},
+		{Spec: "0 10 * * MON#1,MON#3", From: "2024-01-02T00:00:00Z", Next: "2024-01-15T10:00:00Z"},
+		{Spec: "0 10 * * MON#1,MON#3", From: "2024-01-15T10:00:00Z", Next: "2024-02-05T10:00:00Z"},
+		{Spec: "0 10 * * MON#3,FRI", From: "2024-01-13T00:00:00Z", Next: "2024-01-15T10:00:00Z"},
+		{Spec: "0 0 29 2 *", From: "2024-03-01T00:00:00Z", Next: "2028-02-29T00:00:00Z"},
+		{Spec: "@monthly", From: "2024-01-15T00:00:00Z", Next: "2024-02-01T00:00:00Z"},
+	}
+
+	for _, c := range cases {
+		s, err := ParseCron(c.Spec, time.UTC)
+		if err != nil {
+			t.Errorf("%s: %s", c.Spec, err)
+			continue
+		}
+		from, _ := time.Parse(time.RFC3339, c.From)
+		next, _ := time.Parse(time.RFC3339, c.Next)
+		if got := s.Next(from); !got.Equal(next) {
+			t.Errorf("%s: expected %s after %s but got %s", c.Spec, next, from, got)
+		}
+	}
+}
+
+func TestCronSchedule_NextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	cases := []struct {
+		Spec string
+		From string
+		Next string
+	}{
+		// 2:00-3:00 on 2024-03-10 is skipped.
+		{Spec: "30 2 * * *", From: "2024-03-10T00:00:00-05:00", Next: "2024-03-10T03:00:00-04:00"},
+		{Spec: "30 2 * * *", From: "2024-03-10T03:00:00-04:00", Next: "2024-03-11T02:30:00-04:00"},
+		{Spec: "0 * * * *", From: "2024-03-10T01:00:00-05:00", Next: "2024-03-10T03:00:00-04:00"},
+		// 1:00-2:00 on 2024-11-03 is repeated.
+		{Spec: "30 1 * * *", From: "2024-11-03T00:00:00-04:00", Next: "2024-11-03T01:30:00-04:00"},
+		{Spec: "30 1 * * *", From: "2024-11-03T01:30:00-04:00", Next: "2024-11-04T01:30:00-05:00"},
+		{Spec: "30 * * * *", From: "2024-11-03T01:30:00-04:00", Next: "2024-11-03T01:30:00-05:00"},
+	}
+
+	for _, c := range cases {
+		s, err := ParseCron(c.Spec, loc)
+		if err != nil {
+			t.Errorf("%s: %s", c.Spec, err)
+			continue
+		}
+		from, _ := time.Parse(time.RFC3339, c.From)
+		next, _ := time.Parse(time.RFC3339, c.Next)
+		if got := s.Next(from); !got.Equal(next) {
+			t.Errorf("%s: expected %s after %s but got %s", c.Spec, next.In(loc), from.In(loc), got)
+		}
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * FOO *",
+		"0 10 * * MON#0",
+		"0 10 * * MON#6",
+		"0 10 * * MON#x",
+		"0 0 30 2 *",
+		"0 0 31 4,6,9,11 *",
+	}
+
+	for _, spec := range specs {
+		if _, err := ParseCron(spec, time.UTC); err == nil {
+			t.Errorf("%q: expected the error", spec)
+		}
+	}
+}
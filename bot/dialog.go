@@ -0,0 +1,384 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dialogTableName = "dialogs"
+	// DialogEnd is returned by DialogStep.Next for finishing the dialog.
+	DialogEnd = ""
+)
+
+var (
+	ErrDialogNotFound = errors.New("dialog not found")
+	ErrDialogCanceled = errors.New("dialog canceled")
+	ErrDialogTimeout  = errors.New("dialog timed out")
+	// ErrDialogStepNotFound is given to OnCancel when the dialog reaches the step which doesn't exist.
+	ErrDialogStepNotFound = errors.New("dialog step not found")
+
+	// DialogCancelKeyword is the message which cancels the dialog in progress.
+	DialogCancelKeyword  = "cancel"
+	DefaultDialogTimeout = 5 * time.Minute
+)
+
+// DialogStep is a question of the dialog.
+type DialogStep struct {
+	Name   string
+	Prompt string
+	// Type is used for validating and converting the answer.
+	Type ArgType
+	// Validate checks the answer additionally.
+	// If the answer is invalid, the error is sent to the user and the step is asked again.
+	Validate func(answer string) error
+	// Next returns the name of the next step.
+	// If Next is nil, the following step in Dialog.Steps is asked.
+	Next func(answer string, result *DialogResult) string
+}
+
+// Dialog is a multi-step conversation with a user.
+// Dialog must be registered by Bot.RegisterDialog before Bot.Start, so that
+// the conversation in progress can be resumed after a restart.
+type Dialog struct {
+	Name    string
+	Steps   []DialogStep
+	Timeout time.Duration
+	// OnComplete is called with the answers when the dialog is finished.
+	OnComplete func(*Event, *DialogResult)
+	// OnCancel is called with ErrDialogCanceled or ErrDialogTimeout when the dialog is aborted.
+	OnCancel func(*Event, error)
+}
+
+// DialogResult holds the answers which are converted by DialogStep.Type.
+type DialogResult struct {
+	*Arguments
+	Answers map[string]string
+}
+
+// dialogState is the conversation in progress which is stored in Persistence.
+type dialogState struct {
+	Dialog   string            `json:"dialog"`
+	Channel  string            `json:"channel"`
+	Thread   string            `json:"thread"`
+	User     string            `json:"user"`
+	Step     string            `json:"step"`
+	Answers  map[string]string `json:"answers"`
+	Deadline time.Time         `json:"deadline"`
+
+	// timer aborts the dialog at Deadline.
+	timer Timer
+}
+
+type DialogManager struct {
+	bot     *Bot
+	dialogs map[string]*Dialog
+	states  map[string]*dialogState
+	mutex   sync.Mutex
+}
+
+func NewDialogManager(bot *Bot) *DialogManager {
+	return &DialogManager{
+		bot:     bot,
+		dialogs: make(map[string]*Dialog),
+		states:  make(map[string]*dialogState),
+	}
+}
+
+func (m *DialogManager) Register(dialog *Dialog) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.dialogs[dialog.Name] = dialog
+}
+
+// Start begins the dialog with the user of the event.
+// If the event is in a thread, the dialog continues in the thread.
+func (m *DialogManager) Start(event *Event, name string) error {
+	m.mutex.Lock()
+	dialog, ok := m.dialogs[name]
+	if !ok || len(dialog.Steps) == 0 {
+		m.mutex.Unlock()
+		return ErrDialogNotFound
+	}
+
+	state := &dialogState{
+		Dialog:  name,
+		Channel: event.Channel,
+		User:    event.User.Id,
+		Step:    dialog.Steps[0].Name,
+		Answers: make(map[string]string),
+	}
+	if event.inThread || event.ThreadTs != "" {
+		state.Thread = event.ThreadRoot()
+	}
+	err := m.save(dialog, state)
+	m.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.bot.Send(m.replyEvent(state), dialog.Steps[0].Prompt)
+	return nil
+}
+
+// Restore loads the conversations in progress from Persistence.
+func (m *DialogManager) Restore() error {
+	keys, err := m.bot.Persistence.List(dialogTableName)
+	if err != nil {
+		// The table doesn't exist until the first dialog is saved.
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, key := range keys {
+		buf, err := m.bot.Persistence.Get(dialogTableName, key)
+		if err != nil || buf == nil {
+			continue
+		}
+		state := &dialogState{}
+		if err := json.Unmarshal(buf, state); err != nil {
+			log.Printf("failed to restore dialog %s: %s", key, err)
+			continue
+		}
+		if _, ok := m.dialogs[state.Dialog]; !ok {
+			continue
+		}
+
+		m.states[key] = state
+		m.watch(key, state)
+	}
+
+	return nil
+}
+
+// Handle processes the message as the answer of the dialog in progress.
+// It returns false if the user doesn't have any dialog in the channel.
+// The callbacks of the dialog are called through the middlewares like the callback of the command.
+func (m *DialogManager) Handle(event *Event) bool {
+	key := dialogKey(event.Channel, event.ThreadTs, event.User.Id)
+
+	m.mutex.Lock()
+	state, ok := m.states[key]
+	if !ok {
+		m.mutex.Unlock()
+		return false
+	}
+	dialog := m.dialogs[state.Dialog]
+	current := state.copy()
+	m.mutex.Unlock()
+	reply := m.replyEvent(current)
+
+	answer := strings.TrimSpace(event.Message)
+	if answer == DialogCancelKeyword {
+		m.abort(key, state, dialog, reply, "canceled", ErrDialogCanceled)
+		return true
+	}
+
+	step, index, ok := dialog.step(current.Step)
+	if !ok {
+		log.Printf("dialog %s doesn't have the step: %s", dialog.Name, current.Step)
+		m.abort(key, state, dialog, reply, "the dialog is aborted because of the unknown step", ErrDialogStepNotFound)
+		return true
+	}
+
+	m.run(event, func(_ *Event) {
+		if err := step.validate(answer); err != nil {
+			m.bot.Send(reply, fmt.Sprintf("%s\n%s", err, step.Prompt))
+			return
+		}
+
+		current.Answers[step.Name] = answer
+		var next string
+		if step.Next != nil {
+			next = step.Next(answer, dialog.result(current))
+		} else if index+1 < len(dialog.Steps) {
+			next = dialog.Steps[index+1].Name
+		}
+		if next == DialogEnd {
+			m.mutex.Lock()
+			if m.states[key] != state {
+				// The dialog has been finished by the other answer.
+				m.mutex.Unlock()
+				return
+			}
+			m.remove(key)
+			m.mutex.Unlock()
+			if dialog.OnComplete != nil {
+				dialog.OnComplete(reply, dialog.result(current))
+			}
+			return
+		}
+
+		nextStep, _, ok := dialog.step(next)
+		if !ok {
+			log.Printf("dialog %s doesn't have the step: %s", dialog.Name, next)
+			m.abort(key, state, dialog, reply, "the dialog is aborted because of the unknown step", ErrDialogStepNotFound)
+			return
+		}
+		current.Step = next
+		m.mutex.Lock()
+		if m.states[key] != state {
+			// The dialog has been finished while the answer is processed.
+			m.mutex.Unlock()
+			return
+		}
+		err := m.save(dialog, current)
+		m.mutex.Unlock()
+		if err != nil {
+			log.Printf("failed to save dialog: %s", err)
+		}
+		m.bot.Send(reply, nextStep.Prompt)
+	})
+
+	return true
+}
+
+// abort finishes the dialog and calls OnCancel with the reason.
+func (m *DialogManager) abort(key string, state *dialogState, dialog *Dialog, reply *Event, text string, reason error) {
+	m.mutex.Lock()
+	if m.states[key] != state {
+		m.mutex.Unlock()
+		return
+	}
+	m.remove(key)
+	m.mutex.Unlock()
+
+	m.bot.Send(reply, text)
+	if dialog.OnCancel != nil {
+		m.run(reply, func(event *Event) {
+			dialog.OnCancel(event, reason)
+		})
+	}
+}
+
+// run calls f through the middlewares.
+func (m *DialogManager) run(event *Event, f HandlerFunc) {
+	chain(f, m.bot.eventHandler.Middlewares())(event)
+}
+
+// save stores the state with the new deadline.
+// The caller must hold the lock.
+func (m *DialogManager) save(dialog *Dialog, state *dialogState) error {
+	timeout := dialog.Timeout
+	if timeout == 0 {
+		timeout = DefaultDialogTimeout
+	}
+	state.Deadline = m.bot.clock.Now().Add(timeout)
+
+	buf, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	key := dialogKey(state.Channel, state.Thread, state.User)
+	if old, ok := m.states[key]; ok {
+		old.stop()
+	}
+	m.states[key] = state
+	m.watch(key, state)
+
+	return m.bot.Persistence.Set(dialogTableName, key, buf)
+}
+
+// remove deletes the state.
+// The caller must hold the lock.
+func (m *DialogManager) remove(key string) {
+	if state, ok := m.states[key]; ok {
+		state.stop()
+	}
+	delete(m.states, key)
+	if err := m.bot.Persistence.Delete(dialogTableName, key); err != nil {
+		log.Printf("failed to delete dialog: %s", err)
+	}
+}
+
+// watch aborts the dialog when the deadline of the state is passed without any answer.
+// The caller must hold the lock.
+func (m *DialogManager) watch(key string, state *dialogState) {
+	state.timer = m.bot.clock.AfterFunc(state.Deadline.Sub(m.bot.clock.Now()), func() {
+		m.mutex.Lock()
+		if m.states[key] != state {
+			m.mutex.Unlock()
+			return
+		}
+		dialog := m.dialogs[state.Dialog]
+		m.mutex.Unlock()
+
+		m.abort(key, state, dialog, m.replyEvent(state), "timed out", ErrDialogTimeout)
+	})
+}
+
+func (m *DialogManager) replyEvent(state *dialogState) *Event {
+	return &Event{
+		Type:     MessageEvent,
+		Channel:  state.Channel,
+		ThreadTs: state.Thread,
+		User:     User{Id: state.User},
+		Bot:      m.bot,
+		inThread: state.Thread != "",
+	}
+}
+
+// step returns the step of the name. ok is false if the dialog doesn't have the step.
+func (dialog *Dialog) step(name string) (step DialogStep, index int, ok bool) {
+	for i, s := range dialog.Steps {
+		if s.Name == name {
+			return s, i, true
+		}
+	}
+
+	return DialogStep{}, 0, false
+}
+
+func (dialog *Dialog) result(state *dialogState) *DialogResult {
+	result := &DialogResult{Arguments: &Arguments{values: make(map[string]interface{})}, Answers: state.Answers}
+	for _, s := range dialog.Steps {
+		if answer, ok := state.Answers[s.Name]; ok {
+			result.set(ArgSpec{Name: s.Name, Type: s.Type}, answer)
+		}
+	}
+
+	return result
+}
+
+func (step DialogStep) validate(answer string) error {
+	args := &Arguments{values: make(map[string]interface{})}
+	if err := args.set(ArgSpec{Name: step.Name, Type: step.Type}, answer); err != nil {
+		return err
+	}
+	if step.Validate != nil {
+		return step.Validate(answer)
+	}
+
+	return nil
+}
+
+// stop stops the timer of the state.
+func (state *dialogState) stop() {
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+}
+
+// copy returns the state which has the copy of the answers.
+// The copy doesn't have the timer, which is armed when the copy is saved.
+func (state *dialogState) copy() *dialogState {
+	c := *state
+	c.timer = nil
+	c.Answers = make(map[string]string, len(state.Answers))
+	for k, v := range state.Answers {
+		c.Answers[k] = v
+	}
+
+	return &c
+}
+
+func dialogKey(channel, thread, user string) string {
+	return channel + ":" + thread + ":" + user
+}
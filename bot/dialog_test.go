@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func newSignupDialog(onComplete func(*Event, *DialogResult), onCancel func(*Event, error)) *Dialog {
+	return &Dialog{
+		Name: "signup",
+		Steps: []DialogStep{
+			{Name: "name", Prompt: "name?"},
+			{Name: "age", Prompt: "age?", Type: IntArg},
+		},
+		OnComplete: onComplete,
+		OnCancel:   onCancel,
+	}
+}
+
+func TestDialog(t *testing.T) {
+	b, connector := newTestBot()
+	canceled := make(chan error, 1)
+	b.RegisterDialog(newSignupDialog(func(event *Event, result *DialogResult) {
+		event.Sayf("welcome %s (%d)", result.String("name"), result.Int("age"))
+	}, func(_ *Event, err error) {
+		canceled <- err
+	}))
+	stop := runTestBot(b)
+	defer stop()
+	b.Command("signup", "", func(event *Event) {
+		event.StartDialog("signup")
+	})
+
+	transcript := connector.Transcript(t, "C1")
+	transcript.Expect("U1", "bot signup").Reply("name?")
+	transcript.Expect("U2", "bob").NoReply()
+	transcript.Expect("U1", "alice").Reply("age?")
+	transcript.Expect("U1", "twenty").Reply("age must be an integer: twenty\nage?")
+	transcript.Expect("U1", "20").Reply("welcome alice (20)")
+	transcript.Expect("U1", "21").NoReply()
+
+	transcript.Expect("U1", "bot signup").Reply("name?")
+	transcript.Expect("U1", "cancel").Reply("canceled")
+	transcript.Expect("U1", "alice").NoReply()
+	if err := <-canceled; err != ErrDialogCanceled {
+		t.Fatalf("OnCancel is not called with ErrDialogCanceled: %v", err)
+	}
+}
+
+func TestDialog_Timeout(t *testing.T) {
+	b, connector := newTestBot()
+	clock := NewTestClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	b.SetClock(clock)
+	canceled := make(chan error, 1)
+	b.RegisterDialog(newSignupDialog(nil, func(_ *Event, err error) {
+		canceled <- err
+	}))
+	answer := func(text string) {
+		b.dialogs.Handle(&Event{Type: MessageEvent, Channel: "C1", User: User{Id: "U1"}, Message: text, Bot: b})
+	}
+
+	if err := b.StartDialog(&Event{Channel: "C1", User: User{Id: "U1"}, Bot: b}, "signup"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(DefaultDialogTimeout - time.Minute)
+	answer("alice")
+	// The deadline is extended by the answer, and the timer of the previous deadline is stopped.
+	if n := len(clock.waiters); n != 1 {
+		t.Fatalf("expected 1 timer: %d", n)
+	}
+	clock.Advance(DefaultDialogTimeout - time.Minute)
+	answer("twenty")
+	if len(canceled) != 0 {
+		t.Fatal("the dialog is timed out before the deadline")
+	}
+	clock.Advance(DefaultDialogTimeout)
+	if err := <-canceled; err != ErrDialogTimeout {
+		t.Fatalf("OnCancel is not called with ErrDialogTimeout: %v", err)
+	}
+
+	texts := make([]string, 0)
+	for _, m := range connector.Messages() {
+		texts = append(texts, m.Text)
+	}
+	expected := []string{"name?", "age?", "age must be an integer: twenty\nage?", "timed out"}
+	if len(texts) != len(expected) || texts[3] != expected[3] {
+		t.Fatalf("unexpected messages: %q", texts)
+	}
+	if b.dialogs.Handle(&Event{Type: MessageEvent, Channel: "C1", User: User{Id: "U1"}, Message: "20", Bot: b}) {
+		t.Fatal("the answer is handled after the timeout")
+	}
+}
+
+func TestDialog_ConcurrentAnswers(t *testing.T) {
+	b, _ := newTestBot()
+	var completed int
+	var mutex sync.Mutex
+	dialog := newSignupDialog(func(_ *Event, _ *DialogResult) {
+		mutex.Lock()
+		completed++
+		mutex.Unlock()
+	}, nil)
+	// Both answers are validated before either of them finishes the dialog.
+	var validating sync.WaitGroup
+	validating.Add(2)
+	dialog.Steps[1].Validate = func(_ string) error {
+		validating.Done()
+		validating.Wait()
+		return nil
+	}
+	b.RegisterDialog(dialog)
+
+	if err := b.StartDialog(&Event{Channel: "C1", User: User{Id: "U1"}, Bot: b}, "signup"); err != nil {
+		t.Fatal(err)
+	}
+	b.dialogs.Handle(&Event{Type: MessageEvent, Channel: "C1", User: User{Id: "U1"}, Message: "alice", Bot: b})
+
+	var wg sync.WaitGroup
+	for _, age := range []string{"20", "21"} {
+		age := age
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.dialogs.Handle(&Event{Type: MessageEvent, Channel: "C1", User: User{Id: "U1"}, Message: age, Bot: b})
+		}()
+	}
+	wg.Wait()
+
+	if completed != 1 {
+		t.Fatalf("OnComplete is called %d times", completed)
+	}
+}
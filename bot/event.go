@@ -7,22 +7,41 @@ import (
 )
 
 type Event struct {
-	Type        string
-	Message     string
-	Argv        []string
-	Channel     string
-	User        User
-	Reaction    string
-	Ts          string
-	Timestamp   time.Time
-	MentionName string
-	Bot         *Bot
+	Type    string
+	Message string
+	// PreviousMessage is the text before the message is edited or deleted.
+	PreviousMessage string
+	Argv            []string
+	Args            *Arguments
+	Channel         string
+	User            User
+	Reaction        string
+	Ts              string
+	ThreadTs        string
+	Timestamp       time.Time
+	MentionName     string
+	Action          *Action
+	// SlashCommand is set if the event is the invocation of the slash command.
+	SlashCommand *SlashCommand
+	Bot          *Bot
+
+	inThread bool
 }
 
 func (event *Event) EventId() string {
 	return event.Channel + event.Ts
 }
 
+// ThreadRoot returns the timestamp of the parent message of the thread which the event belongs to.
+// If the event is not in any thread, the event itself becomes the parent.
+func (event *Event) ThreadRoot() string {
+	if event.ThreadTs != "" {
+		return event.ThreadTs
+	}
+
+	return event.Ts
+}
+
 func (event *Event) ChannelName() (string, error) {
 	channelInfo, err := event.Bot.Connector.GetChannelInfo(event.Channel)
 	if err != nil {
@@ -32,40 +51,108 @@ func (event *Event) ChannelName() (string, error) {
 	return channelInfo.Name, nil
 }
 
-func (event *Event) Say(text string) {
-	event.Bot.Send(event, text)
+func (event *Event) Say(text string) (*SentMessage, error) {
+	return event.Bot.Send(event, text)
+}
+
+func (event *Event) Sayf(format string, a ...interface{}) (*SentMessage, error) {
+	return event.Bot.Sendf(event, format, a...)
+}
+
+func (event *Event) SayRich(message *RichMessage) (*SentMessage, error) {
+	return event.Bot.SendRich(event, message)
+}
+
+func (event *Event) SayWithActions(message *RichMessage, callback func(*Event)) (*SentMessage, error) {
+	return event.Bot.SendWithActions(event, message, callback)
 }
 
-func (event *Event) Sayf(format string, a ...interface{}) {
-	event.Bot.Sendf(event, format, a...)
+func (event *Event) SayInteractive(name string, message *RichMessage, data map[string]string) (*InteractiveMessage, error) {
+	return event.Bot.SendInteractive(event, name, message, data)
 }
 
-func (event *Event) SayWithConfirm(text, reaction string, callback func(*Event)) {
-	event.Bot.SendWithConfirm(event, text, reaction, callback)
+func (event *Event) SayInThread(text string) (*SentMessage, error) {
+	return event.Bot.SendInThread(event, text)
 }
 
-func (event *Event) SayWithConfirmf(reaction string, callback func(*Event), format string, a ...interface{}) {
-	event.Bot.SendWithConfirmf(event, reaction, callback, format, a...)
+func (event *Event) SayInThreadf(format string, a ...interface{}) (*SentMessage, error) {
+	return event.Bot.SendInThread(event, fmt.Sprintf(format, a...))
 }
 
-func (event *Event) SayAndRequestReactionFromOther(text, reaction string, callback func(*Event)) {
-	event.Bot.SendAndRequestReactFromOther(event, text, reaction, callback)
+func (event *Event) SayWithConfirm(text, reaction string, callback func(*Event)) (*SentMessage, error) {
+	return event.Bot.SendWithConfirm(event, text, reaction, callback)
 }
 
-func (event *Event) SayRequireResponse(text string) (func(), chan string) {
+func (event *Event) SayWithConfirmf(reaction string, callback func(*Event), format string, a ...interface{}) (*SentMessage, error) {
+	return event.Bot.SendWithConfirmf(event, reaction, callback, format, a...)
+}
+
+func (event *Event) SayAndRequestReactionFromOther(text, reaction string, callback func(*Event)) (*SentMessage, error) {
+	return event.Bot.SendAndRequestReactFromOther(event, text, reaction, callback)
+}
+
+func (event *Event) SayRequireResponse(text string) (func(), chan string, error) {
 	return event.Bot.SendRequireResponse(event, text)
 }
 
-func (event *Event) SayRequireResponsef(format string, a ...interface{}) (func(), chan string) {
+func (event *Event) SayRequireResponsef(format string, a ...interface{}) (func(), chan string, error) {
 	return event.Bot.SendRequireResponsef(event, format, a...)
 }
 
-func (event *Event) Reply(text string) {
-	event.Bot.Sendf(event, "%l: %s", event.User, text)
+func (event *Event) Reply(text string) (*SentMessage, error) {
+	return event.Bot.Sendf(event, "%l: %s", event.User, text)
+}
+
+func (event *Event) Replyf(format string, a ...interface{}) (*SentMessage, error) {
+	return event.Bot.Sendf(event, fmt.Sprintf("%l: %s", event.User, format), a...)
+}
+
+func (event *Event) ReplyInThread(text string) (*SentMessage, error) {
+	return event.Bot.SendInThread(event, fmt.Sprintf("%l: %s", event.User, text))
 }
 
-func (event *Event) Replyf(format string, a ...interface{}) {
-	event.Bot.Sendf(event, fmt.Sprintf("%l: %s", event.User, format), a...)
+func (event *Event) ReplyInThreadf(format string, a ...interface{}) (*SentMessage, error) {
+	return event.Bot.SendInThread(event, fmt.Sprintf(fmt.Sprintf("%l: %s", event.User, format), a...))
+}
+
+// Ack answers the slash command with the text which is shown only to the user.
+// If the acknowledgement is too late, the text is sent by Respond instead.
+func (event *Event) Ack(text string) error {
+	if event.SlashCommand == nil {
+		return ErrNotSlashCommand
+	}
+	if event.SlashCommand.Ack(text) {
+		return nil
+	}
+
+	return event.Respond(text)
+}
+
+// Respond sends the delayed response of the slash command which is shown only to the user.
+func (event *Event) Respond(text string) error {
+	if event.SlashCommand == nil {
+		return ErrNotSlashCommand
+	}
+
+	return event.Bot.Connector.RespondSlashCommand(event, text, false)
+}
+
+// RespondInChannel sends the delayed response of the slash command to everyone in the channel.
+func (event *Event) RespondInChannel(text string) error {
+	if event.SlashCommand == nil {
+		return ErrNotSlashCommand
+	}
+
+	return event.Bot.Connector.RespondSlashCommand(event, text, true)
+}
+
+func (event *Event) StartDialog(name string) error {
+	return event.Bot.StartDialog(event, name)
+}
+
+// Progress posts the text which is edited while a long task runs.
+func (event *Event) Progress(text string) (*Progress, error) {
+	return event.Bot.Progress(event, text)
 }
 
 func (event *Event) WithIndicate(f func() error) {
@@ -76,12 +163,21 @@ func (event *Event) Attach(title, fileName string, file io.Reader) error {
 	return event.Bot.Attach(event, title, fileName, file)
 }
 
-func (event *Event) Direct(text string) {
-	event.Bot.SendPrivate(event, text)
+func (event *Event) Direct(text string) (*SentMessage, error) {
+	return event.Bot.SendPrivate(event, text)
+}
+
+func (event *Event) Directf(format string, a ...interface{}) (*SentMessage, error) {
+	return event.Bot.SendPrivate(event, fmt.Sprintf(format, a...))
+}
+
+// Whisper sends the text which is shown only to the user of the event.
+func (event *Event) Whisper(text string) error {
+	return event.Bot.SendEphemeral(event, text)
 }
 
-func (event *Event) Directf(format string, a ...interface{}) {
-	event.Bot.SendPrivate(event, fmt.Sprintf(format, a...))
+func (event *Event) Whisperf(format string, a ...interface{}) error {
+	return event.Bot.SendEphemeral(event, fmt.Sprintf(format, a...))
 }
 
 func (event *Event) Permalink() string {
@@ -1,6 +1,8 @@
 package bot
 
 import (
+	"fmt"
+	"log"
 	"regexp"
 	"strings"
 	"sync"
@@ -10,29 +12,47 @@ import (
 type EventHandler struct {
 	OnError OnError
 
-	accept      bool
-	acceptUsers map[string]bool
-	ignoreUsers map[string]bool
-	commands    map[string][]Command
-	mutex       *sync.RWMutex
+	accept       bool
+	acceptUsers  map[string]bool
+	ignoreUsers  map[string]bool
+	commands     map[string][]Command
+	middlewares  []Middleware
+	roles        *RoleManager
+	dialogs      *DialogManager
+	interactions *InteractionManager
+	clock        Clock
+	mutex        *sync.RWMutex
+	// pending counts the changes of commands which are running in the background.
+	pending *sync.WaitGroup
 }
 
 type Command struct {
 	CommandType string
 
 	eventType                string
+	name                     string
 	description              string
 	pattern                  *regexp.Regexp
 	channel                  string
+	thread                   string
 	user                     string
 	argv                     bool
 	messageId                string
 	reaction                 string
 	requestReactionFromOther bool
+	inThread                 bool
+	roles                    []string
+	args                     ArgSpecs
+	group                    *CommandGroup
 	callback                 func(*Event)
 	createdAt                time.Time
+	once                     *sync.Once
+	rerunOnEdit              bool
 }
 
+// CommandOption changes the behavior of the command.
+type CommandOption func(*Command)
+
 const (
 	CommandTypeRequireResponse = "require_response"
 )
@@ -41,6 +61,28 @@ var (
 	ReactionExpire = 3 * time.Minute
 )
 
+// InThread makes the command always answer in the thread it was invoked from.
+func InThread() CommandOption {
+	return func(command *Command) {
+		command.inThread = true
+	}
+}
+
+// RerunOnEdit runs the command again when the user edits the message, e.g. for fixing a typo.
+// The command is not run again if the message before the edit has already run any command.
+func RerunOnEdit() CommandOption {
+	return func(command *Command) {
+		command.rerunOnEdit = true
+	}
+}
+
+// RequireRole allows only the users who have any of roles to run the command.
+func RequireRole(roles ...string) CommandOption {
+	return func(command *Command) {
+		command.roles = append(command.roles, roles...)
+	}
+}
+
 func NewEventHandler(ignoreUsers []string, acceptUsers []string) *EventHandler {
 	accept := false
 	acceptMap := make(map[string]bool)
@@ -61,15 +103,50 @@ func NewEventHandler(ignoreUsers []string, acceptUsers []string) *EventHandler {
 		acceptUsers: acceptMap,
 		ignoreUsers: ignoreMap,
 		commands:    make(map[string][]Command, 0),
+		clock:       SystemClock,
 		mutex:       &sync.RWMutex{},
+		pending:     &sync.WaitGroup{},
 	}
 }
 
-func (eventHandler *EventHandler) AddCommand(pattern *regexp.Regexp, description string, callback func(*Event), argv bool) {
+func (eventHandler *EventHandler) Use(middleware Middleware) {
+	eventHandler.mutex.Lock()
+	defer eventHandler.mutex.Unlock()
+
+	eventHandler.middlewares = append(eventHandler.middlewares, middleware)
+}
+
+func (eventHandler *EventHandler) Middlewares() []Middleware {
+	eventHandler.mutex.RLock()
+	defer eventHandler.mutex.RUnlock()
+
+	middlewares := make([]Middleware, len(eventHandler.middlewares))
+	copy(middlewares, eventHandler.middlewares)
+	return middlewares
+}
+
+func (eventHandler *EventHandler) AddCommand(pattern *regexp.Regexp, description string, callback func(*Event), argv bool, opts ...CommandOption) {
 	command := &Command{pattern: pattern, description: description, callback: callback, argv: argv}
+	for _, opt := range opts {
+		opt(command)
+	}
 	eventHandler.AddHandler(MessageEvent, command)
 }
 
+// AddSlashCommand registers the handler of the slash command.
+// The handler can answer the invocation by Event.Ack, and it is acknowledged without any text when the handler returns.
+func (eventHandler *EventHandler) AddSlashCommand(name, description string, callback func(*Event), opts ...CommandOption) {
+	command := &Command{description: description, callback: func(event *Event) {
+		defer event.SlashCommand.Ack("")
+		callback(event)
+	}}
+	for _, opt := range opts {
+		opt(command)
+	}
+	command.name = slashCommandName(name)
+	eventHandler.AddHandler(SlashCommandEvent, command)
+}
+
 func (eventHandler *EventHandler) Appearance(user string, callback func(*Event)) {
 	command := &Command{user: user, callback: callback}
 	eventHandler.AddHandler(UserTypingEvent, command)
@@ -80,14 +157,46 @@ func (eventHandler *EventHandler) WatchReaction(reaction string, callback func(*
 	eventHandler.AddHandler(ReactionAddedEvent, command)
 }
 
+// WatchReactionRemoved calls callback when the reaction is removed from any message.
+func (eventHandler *EventHandler) WatchReactionRemoved(reaction string, callback func(*Event)) {
+	command := &Command{reaction: reaction, callback: callback}
+	eventHandler.AddHandler(ReactionRemovedEvent, command)
+}
+
+// MemberJoined calls callback when the user joins the channel. If channel is empty, any channel is watched.
+func (eventHandler *EventHandler) MemberJoined(channel string, callback func(*Event)) {
+	command := &Command{channel: channel, callback: callback}
+	eventHandler.AddHandler(MemberJoinedChannelEvent, command)
+}
+
 func (eventHandler *EventHandler) RequireReaction(channel, id, reaction, userId string, callback func(*Event)) {
-	c := &Command{messageId: channel + id, reaction: reaction, user: userId, callback: callback, createdAt: time.Now()}
-	go eventHandler.AddHandler(ReactionAddedEvent, c)
+	c := &Command{messageId: channel + id, reaction: reaction, user: userId, callback: callback, createdAt: eventHandler.clock.Now()}
+	eventHandler.background(func() { eventHandler.AddHandler(ReactionAddedEvent, c) })
 }
 
 func (eventHandler *EventHandler) RequireReactionByOther(channel, id, reaction, userId string, callback func(*Event)) {
-	c := &Command{messageId: channel + id, reaction: reaction, callback: callback, user: userId, requestReactionFromOther: true, createdAt: time.Now()}
-	go eventHandler.AddHandler(ReactionAddedEvent, c)
+	c := &Command{messageId: channel + id, reaction: reaction, callback: callback, user: userId, requestReactionFromOther: true, createdAt: eventHandler.clock.Now()}
+	eventHandler.background(func() { eventHandler.AddHandler(ReactionAddedEvent, c) })
+}
+
+// RequireAction calls callback when the user operates the button or the menu of the message.
+// The callback is called only once, and is not kept over a restart. Use Interaction for persisting it.
+func (eventHandler *EventHandler) RequireAction(channel, id string, callback func(*Event)) {
+	c := &Command{messageId: channel + id, callback: callback, once: &sync.Once{}}
+	eventHandler.background(func() { eventHandler.AddHandler(InteractionEvent, c) })
+}
+
+func (eventHandler *EventHandler) RemoveRequireAction(eventId string) {
+	eventHandler.mutex.Lock()
+	defer eventHandler.mutex.Unlock()
+	newCommands := make([]Command, 0)
+	for _, c := range eventHandler.commands[InteractionEvent] {
+		if c.messageId == eventId {
+			continue
+		}
+		newCommands = append(newCommands, c)
+	}
+	eventHandler.commands[InteractionEvent] = newCommands
 }
 
 func (eventHandler *EventHandler) RemoveRequireReaction(eventId, reaction string) {
@@ -103,26 +212,28 @@ func (eventHandler *EventHandler) RemoveRequireReaction(eventId, reaction string
 	eventHandler.commands[ReactionAddedEvent] = newCommands
 }
 
-func (eventHandler *EventHandler) RequireResponse(channel, user string) (func(), chan string) {
+// RequireResponse waits for the message from the user in the channel.
+// If thread is not empty, only the message in the thread is accepted.
+func (eventHandler *EventHandler) RequireResponse(channel, user, thread string) (func(), chan string) {
 	resChan := make(chan string)
 	callback := func(msg *Event) {
 		resChan <- msg.Message
 	}
 	cancelFunc := func() {
-		go eventHandler.RemoveRequireResponse(channel, user)
+		eventHandler.background(func() { eventHandler.RemoveRequireResponse(channel, user, thread) })
 	}
-	c := &Command{CommandType: CommandTypeRequireResponse, channel: channel, user: user, callback: callback}
-	go eventHandler.AddHandler(MessageEvent, c)
+	c := &Command{CommandType: CommandTypeRequireResponse, channel: channel, thread: thread, user: user, callback: callback}
+	eventHandler.background(func() { eventHandler.AddHandler(MessageEvent, c) })
 	return cancelFunc, resChan
 }
 
-func (eventHandler *EventHandler) RemoveRequireResponse(channel, user string) {
+func (eventHandler *EventHandler) RemoveRequireResponse(channel, user, thread string) {
 	eventHandler.mutex.Lock()
 	defer eventHandler.mutex.Unlock()
 
 	newCommands := make([]Command, 0)
 	for _, c := range eventHandler.commands[MessageEvent] {
-		if c.CommandType == CommandTypeRequireResponse && c.channel == channel && c.user == user {
+		if c.CommandType == CommandTypeRequireResponse && c.channel == channel && c.user == user && c.thread == thread {
 			continue
 		}
 		newCommands = append(newCommands, c)
@@ -148,47 +259,60 @@ func (eventHandler *EventHandler) Handle(event *Event, async bool) {
 		return
 	}
 
+	if event.Type == MessageEvent && eventHandler.dialogs != nil && eventHandler.dialogs.Handle(event) {
+		return
+	}
+	if event.Type == InteractionEvent && eventHandler.interactions != nil && eventHandler.interactions.Handle(event) {
+		return
+	}
+
 	eventHandler.mutex.RLock()
 	defer eventHandler.mutex.RUnlock()
 	for _, command := range eventHandler.commands[event.Type] {
 		switch event.Type {
 		case MessageEvent:
-			if command.CommandType == CommandTypeRequireResponse && event.Channel == command.channel && event.User.Id == command.user {
-				eventHandler.commandCallback(command, event, async)
-				return
-			}
 			if command.CommandType == CommandTypeRequireResponse {
+				if event.Channel == command.channel && event.User.Id == command.user && (command.thread == "" || event.ThreadTs == command.thread) {
+					eventHandler.commandCallback(command, event, async)
+					return
+				}
+				// The other commands are not run while the response is awaited.
 				return
 			}
 
-			if command.pattern.MatchString(event.Message) == true {
-				if command.argv == true {
-					matched := command.pattern.FindStringSubmatch(event.Message)
-					event.Argv = strings.Fields(matched[1])
-				}
-
-				eventHandler.commandCallback(command, event, async)
+			if eventHandler.matchCommand(command, event, async, false) {
 				return
 			}
+		case MessageChangedEvent, MessageDeletedEvent, ChannelCreatedEvent:
+			eventHandler.commandCallback(command, event, async)
+		case ReactionRemovedEvent:
+			if event.Reaction == command.reaction {
+				eventHandler.commandCallback(command, event, async)
+			}
+		case MemberJoinedChannelEvent:
+			if command.channel == "" || event.Channel == command.channel {
+				eventHandler.commandCallback(command, event, async)
+			}
 		case UserTypingEvent:
 			if event.User.Id == command.user {
 				eventHandler.commandCallback(command, event, async)
 			}
 		case ReactionAddedEvent:
-			if !command.createdAt.IsZero() && time.Now().Sub(command.createdAt) >= ReactionExpire {
-				go eventHandler.RemoveRequireReaction(command.messageId, command.reaction)
+			if !command.createdAt.IsZero() && eventHandler.clock.Now().Sub(command.createdAt) >= ReactionExpire {
+				messageId, reaction := command.messageId, command.reaction
+				eventHandler.background(func() { eventHandler.RemoveRequireReaction(messageId, reaction) })
 				continue
 			}
 			if command.requestReactionFromOther {
 				if event.EventId() == command.messageId && event.User.Id != command.user && event.Reaction == command.reaction {
-					go eventHandler.RemoveRequireReaction(event.EventId(), event.Reaction)
+					eventHandler.background(func() { eventHandler.RemoveRequireReaction(event.EventId(), event.Reaction) })
 					eventHandler.commandCallback(command, event, async)
 					return
 				}
 				continue
 			} else {
 				if event.EventId() == command.messageId && event.User.Id == command.user && event.Reaction == command.reaction {
-					go eventHandler.RemoveRequireReaction(event.EventId(), event.Reaction)
+					eventHandler.background(func() { eventHandler.RemoveRequireReaction(event.EventId(), event.Reaction) })
 					eventHandler.commandCallback(command, event, async)
 					return
 				}
@@ -196,27 +320,203 @@ func (eventHandler *EventHandler) Handle(event *Event, async bool) {
 			if event.Reaction == command.reaction {
 				eventHandler.commandCallback(command, event, async)
 			}
+		case SlashCommandEvent:
+			if event.SlashCommand == nil || event.SlashCommand.Name != command.name {
+				continue
+			}
+			event.Argv = strings.Fields(event.Message)
+			if !eventHandler.authorize(command, event) {
+				return
+			}
+			if !eventHandler.parseArgs(command, event, event.Message) {
+				return
+			}
+
+			eventHandler.commandCallback(command, event, async)
+			return
+		case InteractionEvent:
+			if event.EventId() == command.messageId {
+				eventHandler.background(func() { eventHandler.RemoveRequireAction(event.EventId()) })
+				// The command is removed asynchronously, so the following action may reach here.
+				command.once.Do(func() {
+					eventHandler.commandCallback(command, event, async)
+				})
+				return
+			}
+		}
+	}
+
+	if event.Type == MessageChangedEvent {
+		eventHandler.rerun(event, async)
+	}
+	if event.SlashCommand != nil {
+		// Nobody handles the command, so Connector doesn't need to wait for the acknowledgement.
+		event.SlashCommand.Ack("")
+	}
+}
+
+// matchCommand runs the command if the message matches the pattern of the command.
+// It returns true if the message matches, even if the command isn't run.
+// If rerun is true, only the command which has RerunOnEdit is run.
+func (eventHandler *EventHandler) matchCommand(command Command, event *Event, async, rerun bool) bool {
+	if !command.pattern.MatchString(event.Message) {
+		return false
+	}
+	if rerun && !command.rerunOnEdit {
+		return true
+	}
+
+	text := ""
+	if command.argv == true {
+		matched := command.pattern.FindStringSubmatch(event.Message)
+		text = matched[1]
+		event.Argv = strings.Fields(text)
+	}
+	if command.group != nil {
+		sub, rest, ok := command.group.route(event, text, eventHandler.authorize)
+		if !ok {
+			return true
+		}
+		command, text = sub, rest
+		event.Argv = strings.Fields(text)
+	}
+	event.inThread = command.inThread
+	if !eventHandler.authorize(command, event) {
+		return true
+	}
+	if !eventHandler.parseArgs(command, event, text) {
+		return true
+	}
+
+	eventHandler.commandCallback(command, event, async)
+	return true
+}
+
+// rerun matches the edited message with the commands as the new message.
+// Only the message which hasn't run any command is matched, so that fixing the typo doesn't run the command twice.
+// The caller must hold the lock.
+func (eventHandler *EventHandler) rerun(event *Event, async bool) {
+	if eventHandler.matchAny(event.PreviousMessage) {
+		return
+	}
+
+	message := *event
+	message.Type = MessageEvent
+	for _, command := range eventHandler.commands[MessageEvent] {
+		if command.CommandType == CommandTypeRequireResponse {
+			continue
+		}
+		if eventHandler.matchCommand(command, &message, async, true) {
+			return
 		}
 	}
 }
 
+// matchAny reports whether the text matches any command of the message.
+// The caller must hold the lock.
+func (eventHandler *EventHandler) matchAny(text string) bool {
+	for _, command := range eventHandler.commands[MessageEvent] {
+		if command.CommandType == CommandTypeRequireResponse {
+			continue
+		}
+		matched := command.pattern.FindStringSubmatch(text)
+		if matched == nil {
+			continue
+		}
+		if command.group == nil {
+			return true
+		}
+		args := ""
+		if command.argv {
+			args = matched[1]
+		}
+		if command.group.lookup(args) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authorize reports whether the user of the event is allowed to run the command.
+// The denied attempt is logged for audit.
+func (eventHandler *EventHandler) authorize(command Command, event *Event) bool {
+	if len(command.roles) == 0 {
+		return true
+	}
+	if eventHandler.roles != nil && eventHandler.roles.Authorize(event.User.Id, command.roles) {
+		return true
+	}
+
+	log.Printf("audit: denied user=%s channel=%s message=%q required_roles=%v", event.User.Id, event.Channel, event.Message, command.roles)
+	eventHandler.notify(event, "you are not allowed to run this command")
+	return false
+}
+
+// parseArgs parses the arguments of the command into the event.
+// If the arguments are invalid, the usage is replied to the user.
+func (eventHandler *EventHandler) parseArgs(command Command, event *Event, text string) bool {
+	if command.args == nil {
+		return true
+	}
+
+	args, err := command.args.Parse(text)
+	if err != nil {
+		eventHandler.notify(event, fmt.Sprintf("%s\nusage: %s %s", err, command.name, command.args.Usage()))
+		return false
+	}
+	event.Argv, _ = SplitWords(text)
+	event.Args = args
+
+	return true
+}
+
+// notify sends the reason why the command isn't run to the user.
+// The text is shown only to the user, and the invocation of the slash command is answered by the acknowledgement.
+func (eventHandler *EventHandler) notify(event *Event, text string) {
+	if event.Bot == nil {
+		return
+	}
+	if event.SlashCommand != nil {
+		event.Ack(text)
+		return
+	}
+
+	event.Whisper(text)
+}
+
+// background changes commands in the background, because the caller may hold the lock.
+func (eventHandler *EventHandler) background(f func()) {
+	eventHandler.pending.Add(1)
+	go func() {
+		defer eventHandler.pending.Done()
+		f()
+	}()
+}
+
+// settle waits for the changes of commands which are running in the background.
+func (eventHandler *EventHandler) settle() {
+	eventHandler.pending.Wait()
+}
+
 func (eventHandler *EventHandler) commandCallback(command Command, event *Event, async bool) {
+	callback := chain(command.callback, eventHandler.middlewares)
 	if async {
-		go func(command Command, event *Event, onError OnError) {
-			eventHandler.commandCallbackWithLog(command, event, onError)
-		}(command, event, eventHandler.OnError)
+		go func(callback HandlerFunc, event *Event, onError OnError) {
+			eventHandler.commandCallbackWithLog(callback, event, onError)
+		}(callback, event, eventHandler.OnError)
 	} else {
-		eventHandler.commandCallbackWithLog(command, event, eventHandler.OnError)
+		eventHandler.commandCallbackWithLog(callback, event, eventHandler.OnError)
 	}
 }
 
-func (eventHandler *EventHandler) commandCallbackWithLog(command Command, event *Event, onError OnError) {
+func (eventHandler *EventHandler) commandCallbackWithLog(callback HandlerFunc, event *Event, onError OnError) {
 	logging := true
 	defer func() {
 		if logging && onError != nil {
 			onError(event)
 		}
 	}()
-	command.callback(event)
+	callback(event)
 	logging = false
 }
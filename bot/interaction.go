@@ -0,0 +1,232 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+)
+
+const (
+	interactionTableName = "interactions"
+)
+
+var (
+	ErrInteractionNotFound = errors.New("interaction not found")
+)
+
+// Interaction handles the actions on the message which is sent by Bot.SendInteractive.
+// Interaction must be registered by Bot.RegisterInteraction before Bot.Start, so that
+// the message which waits for the action, e.g. the pending approval, is handled after a restart.
+//
+//	bot.RegisterInteraction(&bot.Interaction{
+//		Name: "approval",
+//		OnAction: func(e *bot.Event, m *bot.InteractiveMessage) bool {
+//			m.UpdateText(fmt.Sprintf("%s by %l", e.Action.Value, e.User))
+//			return true
+//		},
+//	})
+type Interaction struct {
+	Name string
+	// OnAction is called when the user clicks the button or chooses the option of the message.
+	// If it returns true, the interaction is finished and the following actions are ignored.
+	OnAction func(*Event, *InteractiveMessage) bool
+}
+
+// InteractiveMessage is the message which waits for the actions.
+type InteractiveMessage struct {
+	*SentMessage
+	// Data is the value which is given to SendInteractive. It is stored with the message.
+	Data map[string]string
+
+	bot *Bot
+}
+
+// interactionState is the message waiting for the actions which is stored in Persistence.
+type interactionState struct {
+	Interaction string            `json:"interaction"`
+	Channel     string            `json:"channel"`
+	Ts          string            `json:"ts"`
+	Text        string            `json:"text"`
+	Data        map[string]string `json:"data"`
+
+	running bool
+}
+
+type InteractionManager struct {
+	bot          *Bot
+	interactions map[string]*Interaction
+	states       map[string]*interactionState
+	mutex        sync.Mutex
+}
+
+func NewInteractionManager(bot *Bot) *InteractionManager {
+	return &InteractionManager{
+		bot:          bot,
+		interactions: make(map[string]*Interaction),
+		states:       make(map[string]*interactionState),
+	}
+}
+
+func (m *InteractionManager) Register(interaction *Interaction) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.interactions[interaction.Name] = interaction
+}
+
+// Send posts the message and routes the actions on it to the interaction.
+func (m *InteractionManager) Send(event *Event, name string, message *RichMessage, data map[string]string) (*InteractiveMessage, error) {
+	m.mutex.Lock()
+	_, ok := m.interactions[name]
+	m.mutex.Unlock()
+	if !ok {
+		return nil, ErrInteractionNotFound
+	}
+
+	sent, err := m.bot.SendRich(event, message)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		data = make(map[string]string)
+	}
+
+	state := &interactionState{Interaction: name, Channel: sent.Channel, Ts: sent.Timestamp, Text: sent.Message, Data: data}
+	m.mutex.Lock()
+	err = m.save(state)
+	m.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return state.message(m.bot), nil
+}
+
+// Restore loads the messages waiting for the actions from Persistence.
+func (m *InteractionManager) Restore() error {
+	keys, err := m.bot.Persistence.List(interactionTableName)
+	if err != nil {
+		// The table doesn't exist until the first interaction is saved.
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, key := range keys {
+		buf, err := m.bot.Persistence.Get(interactionTableName, key)
+		if err != nil || buf == nil {
+			continue
+		}
+		state := &interactionState{}
+		if err := json.Unmarshal(buf, state); err != nil {
+			log.Printf("failed to restore interaction %s: %s", key, err)
+			continue
+		}
+		if _, ok := m.interactions[state.Interaction]; !ok {
+			continue
+		}
+
+		m.states[key] = state
+	}
+
+	return nil
+}
+
+// Handle calls the interaction of the message which the action is operated on.
+// It returns false if the message doesn't wait for any action.
+// The action is ignored while the previous action on the same message is being handled.
+// OnAction is called through the middlewares like the callback of the command.
+func (m *InteractionManager) Handle(event *Event) bool {
+	key := interactionKey(event.Channel, event.Ts)
+
+	m.mutex.Lock()
+	state, ok := m.states[key]
+	if !ok {
+		m.mutex.Unlock()
+		return false
+	}
+	if state.running {
+		m.mutex.Unlock()
+		return true
+	}
+	state.running = true
+	interaction := m.interactions[state.Interaction]
+	m.mutex.Unlock()
+
+	message := state.message(m.bot)
+	finished := false
+	chain(func(event *Event) {
+		finished = interaction.OnAction(event, message)
+	}, m.bot.eventHandler.Middlewares())(event)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	state.running = false
+	if finished {
+		m.remove(key)
+		return true
+	}
+	state.Text = message.Message
+	state.Data = message.Data
+	if err := m.save(state); err != nil {
+		log.Printf("failed to save interaction: %s", err)
+	}
+
+	return true
+}
+
+// save stores the state.
+// The caller must hold the lock.
+func (m *InteractionManager) save(state *interactionState) error {
+	buf, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	key := interactionKey(state.Channel, state.Ts)
+	m.states[key] = state
+
+	return m.bot.Persistence.Set(interactionTableName, key, buf)
+}
+
+// remove deletes the state.
+// The caller must hold the lock.
+func (m *InteractionManager) remove(key string) {
+	delete(m.states, key)
+	if err := m.bot.Persistence.Delete(interactionTableName, key); err != nil {
+		log.Printf("failed to delete interaction: %s", err)
+	}
+}
+
+func (state *interactionState) message(bot *Bot) *InteractiveMessage {
+	data := make(map[string]string, len(state.Data))
+	for k, v := range state.Data {
+		data[k] = v
+	}
+
+	return &InteractiveMessage{
+		SentMessage: &SentMessage{Message: state.Text, Channel: state.Channel, Timestamp: state.Ts},
+		Data:        data,
+		bot:         bot,
+	}
+}
+
+// Update replaces the message in place, e.g. for removing the buttons after the approval.
+func (m *InteractiveMessage) Update(message *RichMessage) error {
+	sent, err := m.bot.UpdateRich(m.SentMessage, message)
+	if err != nil {
+		return err
+	}
+	m.SentMessage = sent
+
+	return nil
+}
+
+// UpdateText replaces the message with the text. The buttons and the menus are removed.
+func (m *InteractiveMessage) UpdateText(text string) error {
+	return m.Update(&RichMessage{Text: text})
+}
+
+func interactionKey(channel, ts string) string {
+	return channel + ":" + ts
+}
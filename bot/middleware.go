@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+// HandlerFunc is the function which handles the event.
+type HandlerFunc func(*Event)
+
+// Middleware wraps HandlerFunc for adding the behavior to every callback.
+// Middleware can stop processing the event by not calling next.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// chain builds HandlerFunc which calls f through middlewares.
+// The first middleware becomes the outermost.
+func chain(f HandlerFunc, middlewares []Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		f = middlewares[i](f)
+	}
+
+	return f
+}
+
+// Recover returns the middleware which recovers from panic in the callback.
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(event *Event) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("panic in %s handler: %v\n%s", event.Type, r, debug.Stack())
+				}
+			}()
+
+			next(event)
+		}
+	}
+}
+
+// Logging returns the middleware which logs the event and the elapsed time of the callback.
+func Logging() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(event *Event) {
+			start := time.Now()
+			next(event)
+			log.Printf("%s channel=%s user=%s message=%q elapsed=%s", event.Type, event.Channel, event.User.Id, event.Message, time.Since(start))
+		}
+	}
+}
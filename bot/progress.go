@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ProgressInterval is the minimum interval of editing the message of Progress.
+var ProgressInterval = 1 * time.Second
+
+// Progress is the message which is edited to show the progress of a long task.
+// Unlike WithIndicate, the progress is visible to everyone in the channel without posting many messages.
+//
+//	progress, _ := event.Progress("deploying...")
+//	progress.Update("deploying... 40%")
+//	progress.Done("deployed")
+type Progress struct {
+	bot     *Bot
+	message *SentMessage
+	text    string
+	pending string
+	updated time.Time
+	timer   Timer
+	mutex   sync.Mutex
+}
+
+// Progress posts the text which is edited by Progress.
+func (bot *Bot) Progress(event *Event, text string) (*Progress, error) {
+	message, err := bot.Send(event, text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Progress{bot: bot, message: message, text: text, updated: bot.clock.Now()}, nil
+}
+
+// WithProgress runs f while showing the progress.
+// When f returns, the message is replaced by done, or the error if f fails.
+func (bot *Bot) WithProgress(event *Event, text, done string, f func(*Progress) error) error {
+	progress, err := bot.Progress(event, text)
+	if err != nil {
+		return err
+	}
+
+	if err := f(progress); err != nil {
+		progress.Done(fmt.Sprintf("%s: %s", text, err))
+		return err
+	}
+
+	return progress.Done(done)
+}
+
+// Update edits the message.
+// The edit within ProgressInterval since the last edit is deferred until the interval passes,
+// and only the latest text is applied.
+func (p *Progress) Update(text string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if elapsed := p.bot.clock.Now().Sub(p.updated); elapsed < ProgressInterval {
+		p.pending = text
+		if p.timer == nil {
+			p.timer = p.bot.clock.AfterFunc(ProgressInterval-elapsed, p.flush)
+		}
+		return nil
+	}
+
+	return p.update(text)
+}
+
+func (p *Progress) Updatef(format string, a ...interface{}) error {
+	return p.Update(fmt.Sprintf(format, a...))
+}
+
+// Done edits the message to the final text immediately.
+func (p *Progress) Done(text string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.pending = ""
+	return p.update(text)
+}
+
+// Delete removes the message.
+func (p *Progress) Delete() error {
+	return p.bot.DeleteMessage(p.message)
+}
+
+// Message returns the message which shows the progress.
+func (p *Progress) Message() *SentMessage {
+	return p.message
+}
+
+func (p *Progress) flush() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.timer = nil
+	if p.pending == "" {
+		return
+	}
+	if err := p.update(p.pending); err != nil {
+		log.Printf("failed to update the progress: %s", err)
+	}
+}
+
+// update edits the message if the text is changed. The caller must hold the lock.
+func (p *Progress) update(text string) error {
+	if text == p.text {
+		return nil
+	}
+
+	message, err := p.bot.UpdateMessage(p.message, text)
+	if err != nil {
+		return err
+	}
+	p.message = message
+	p.text = text
+	p.pending = ""
+	p.updated = p.bot.clock.Now()
+
+	return nil
+}
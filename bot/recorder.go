@@ -0,0 +1,261 @@
+package bot
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// The kinds of RecordEntry.
+const (
+	RecordEvent           = "event"
+	RecordSend            = "send"
+	RecordSendWithConfirm = "send_with_confirm"
+	RecordSendInThread    = "send_in_thread"
+	RecordSendPrivate     = "send_private"
+	RecordSendRich        = "send_rich"
+	RecordSendEphemeral   = "send_ephemeral"
+	RecordRespond         = "respond"
+	RecordAttach          = "attach"
+	RecordUpdate          = "update"
+	RecordDelete          = "delete"
+)
+
+// RecordEntry is a line of the recorded JSONL file.
+// The entry of RecordEvent has Event, and the other entries are the calls to Connector.
+type RecordEntry struct {
+	Kind     string         `json:"kind"`
+	Time     *time.Time     `json:"time,omitempty"`
+	Event    *RecordedEvent `json:"event,omitempty"`
+	Channel  string         `json:"channel,omitempty"`
+	ThreadTs string         `json:"thread_ts,omitempty"`
+	User     string         `json:"user,omitempty"`
+	Text     string         `json:"text,omitempty"`
+	FileName string         `json:"file_name,omitempty"`
+	// Ts is the id of the message which is returned by Connector.
+	Ts string `json:"ts,omitempty"`
+}
+
+// RecordedEvent is the serializable copy of Event.
+type RecordedEvent struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+	// PreviousMessage is the text before the message is edited or deleted.
+	PreviousMessage string    `json:"previous_message,omitempty"`
+	Channel         string    `json:"channel,omitempty"`
+	User            User      `json:"user"`
+	Reaction        string    `json:"reaction,omitempty"`
+	Ts              string    `json:"ts,omitempty"`
+	ThreadTs        string    `json:"thread_ts,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+	MentionName     string    `json:"mention_name,omitempty"`
+	Action          *Action   `json:"action,omitempty"`
+	// Command is the name of the slash command.
+	Command string `json:"command,omitempty"`
+}
+
+// RecordingConnector wraps Connector and writes every received event and every sent message to JSONL.
+// The file can be replayed by Replay.
+type RecordingConnector struct {
+	Connector
+
+	w         io.Writer
+	eventChan chan *Event
+	once      sync.Once
+	mutex     sync.Mutex
+}
+
+func NewRecordingConnector(connector Connector, w io.Writer) *RecordingConnector {
+	return &RecordingConnector{Connector: connector, w: w, eventChan: make(chan *Event)}
+}
+
+// ReceivedEvent returns the channel which relays the events of the wrapped Connector after recording them.
+func (c *RecordingConnector) ReceivedEvent() chan *Event {
+	c.once.Do(func() {
+		go func() {
+			for event := range c.Connector.ReceivedEvent() {
+				c.record(&RecordEntry{Kind: RecordEvent, Event: newRecordedEvent(event)})
+				c.eventChan <- event
+			}
+		}()
+	})
+
+	return c.eventChan
+}
+
+func (c *RecordingConnector) Send(event *Event, username, text string) (*SentMessage, error) {
+	sent, err := c.Connector.Send(event, username, text)
+	entry := &RecordEntry{Kind: RecordSend, Channel: event.Channel, Text: text}
+	if sent != nil {
+		entry.Ts = sent.Timestamp
+	}
+	c.record(entry)
+	return sent, err
+}
+
+func (c *RecordingConnector) SendWithConfirm(event *Event, username, text string) (string, error) {
+	ts, err := c.Connector.SendWithConfirm(event, username, text)
+	c.record(&RecordEntry{Kind: RecordSendWithConfirm, Channel: event.Channel, Text: text, Ts: ts})
+	return ts, err
+}
+
+func (c *RecordingConnector) SendInThread(event *Event, username, threadTs, text string) (*SentMessage, error) {
+	sent, err := c.Connector.SendInThread(event, username, threadTs, text)
+	entry := &RecordEntry{Kind: RecordSendInThread, Channel: event.Channel, ThreadTs: threadTs, Text: text}
+	if sent != nil {
+		entry.Ts = sent.Timestamp
+	}
+	c.record(entry)
+	return sent, err
+}
+
+// SendRich records the message as PlainText.
+func (c *RecordingConnector) SendRich(event *Event, username, threadTs string, message *RichMessage) (*SentMessage, error) {
+	sent, err := c.Connector.SendRich(event, username, threadTs, message)
+	entry := &RecordEntry{Kind: RecordSendRich, Channel: event.Channel, ThreadTs: threadTs, Text: message.PlainText()}
+	if sent != nil {
+		entry.Ts = sent.Timestamp
+	}
+	c.record(entry)
+	return sent, err
+}
+
+func (c *RecordingConnector) SendPrivate(event *Event, userId, text string) (*SentMessage, error) {
+	sent, err := c.Connector.SendPrivate(event, userId, text)
+	entry := &RecordEntry{Kind: RecordSendPrivate, User: userId, Text: text}
+	if sent != nil {
+		entry.Ts = sent.Timestamp
+	}
+	c.record(entry)
+	return sent, err
+}
+
+func (c *RecordingConnector) UpdateMessage(message *SentMessage, text string) (*SentMessage, error) {
+	c.record(&RecordEntry{Kind: RecordUpdate, Channel: message.Channel, Text: text, Ts: message.Timestamp})
+	return c.Connector.UpdateMessage(message, text)
+}
+
+func (c *RecordingConnector) UpdateRich(message *SentMessage, rich *RichMessage) (*SentMessage, error) {
+	c.record(&RecordEntry{Kind: RecordUpdate, Channel: message.Channel, Text: rich.PlainText(), Ts: message.Timestamp})
+	return c.Connector.UpdateRich(message, rich)
+}
+
+func (c *RecordingConnector) SendEphemeral(event *Event, userId, text string) error {
+	c.record(&RecordEntry{Kind: RecordSendEphemeral, Channel: event.Channel, ThreadTs: event.ThreadTs, User: userId, Text: text})
+	return c.Connector.SendEphemeral(event, userId, text)
+}
+
+// RespondSlashCommand records the response. The acknowledgement isn't recorded because it doesn't go through Connector.
+func (c *RecordingConnector) RespondSlashCommand(event *Event, text string, inChannel bool) error {
+	c.record(&RecordEntry{Kind: RecordRespond, Channel: event.Channel, User: event.User.Id, Text: text})
+	return c.Connector.RespondSlashCommand(event, text, inChannel)
+}
+
+func (c *RecordingConnector) DeleteMessage(message *SentMessage) error {
+	c.record(&RecordEntry{Kind: RecordDelete, Channel: message.Channel, Ts: message.Timestamp})
+	return c.Connector.DeleteMessage(message)
+}
+
+// Attach records only the file name and the title. The content of the file is not recorded.
+func (c *RecordingConnector) Attach(event *Event, fileName string, file io.Reader, title string) error {
+	c.record(&RecordEntry{Kind: RecordAttach, Channel: event.Channel, FileName: fileName, Text: title})
+	return c.Connector.Attach(event, fileName, file, title)
+}
+
+// GetUserGroupMembers forwards to the wrapped Connector, so that the roles which are assigned to the user groups work.
+// If the wrapped Connector can't retrieve the members, nobody belongs to the group.
+func (c *RecordingConnector) GetUserGroupMembers(groupId string) ([]string, error) {
+	resolver, ok := c.Connector.(UserGroupResolver)
+	if !ok {
+		return nil, nil
+	}
+
+	return resolver.GetUserGroupMembers(groupId)
+}
+
+func (c *RecordingConnector) record(entry *RecordEntry) {
+	now := time.Now()
+	entry.Time = &now
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("failed to record: %s", err)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, err := c.w.Write(append(buf, '\n')); err != nil {
+		log.Printf("failed to record: %s", err)
+	}
+}
+
+// ReadRecords parses the JSONL which is written by RecordingConnector.
+func ReadRecords(r io.Reader) ([]RecordEntry, error) {
+	entries := make([]RecordEntry, 0)
+	decoder := json.NewDecoder(r)
+	for decoder.More() {
+		entry := RecordEntry{}
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// WriteRecords writes the entries as JSONL.
+func WriteRecords(w io.Writer, entries []RecordEntry) error {
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newRecordedEvent(event *Event) *RecordedEvent {
+	e := &RecordedEvent{
+		Type:            event.Type,
+		Message:         event.Message,
+		PreviousMessage: event.PreviousMessage,
+		Channel:         event.Channel,
+		User:            event.User,
+		Reaction:        event.Reaction,
+		Ts:              event.Ts,
+		ThreadTs:        event.ThreadTs,
+		Timestamp:       event.Timestamp,
+		MentionName:     event.MentionName,
+		Action:          event.Action,
+	}
+	if event.SlashCommand != nil {
+		e.Command = event.SlashCommand.Name
+	}
+
+	return e
+}
+
+func (e *RecordedEvent) toEvent() *Event {
+	event := &Event{
+		Type:            e.Type,
+		Message:         e.Message,
+		PreviousMessage: e.PreviousMessage,
+		Channel:         e.Channel,
+		User:            e.User,
+		Reaction:        e.Reaction,
+		Ts:              e.Ts,
+		ThreadTs:        e.ThreadTs,
+		Timestamp:       e.Timestamp,
+		MentionName:     e.MentionName,
+		Action:          e.Action,
+	}
+	if e.Command != "" {
+		event.SlashCommand = NewSlashCommand(e.Command, "")
+	}
+
+	return event
+}
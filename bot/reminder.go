@@ -0,0 +1,178 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownReminderTime = errors.New("unknown time. e.g. \"in 30m\", \"tomorrow 9:00\", \"at 15:00 on friday\"")
+
+	weekdayPattern      = `sunday|monday|tuesday|wednesday|thursday|friday|saturday|sun|mon|tue|wed|thu|fri|sat`
+	remindInPattern     = regexp.MustCompile(`(?is)\Ain\s+(\d+)\s*(s|secs?|seconds?|m|mins?|minutes?|h|hrs?|hours?|d|days?)\s+(?:to\s+)?(.+)\z`)
+	remindInGoPattern   = regexp.MustCompile(`(?is)\Ain\s+((?:\d+[hms])+)\s+(?:to\s+)?(.+)\z`)
+	remindAtPattern     = regexp.MustCompile(`(?is)\A(?:at\s+)?(\d{1,2}:\d{2})(?:\s+(?:on\s+)?(today|tomorrow|` + weekdayPattern + `))?\s+(?:to\s+)?(.+)\z`)
+	remindOnDayPattern  = regexp.MustCompile(`(?is)\A(?:on\s+)?(today|tomorrow|` + weekdayPattern + `)(?:\s+at)?\s+(\d{1,2}:\d{2})\s+(?:to\s+)?(.+)\z`)
+	remindTargetPattern = regexp.MustCompile(`(?is)\A(?:(--dm)\s+)?(?:me\s+)?(.+)\z`)
+)
+
+// Reminder is the message which is sent once at the time.
+// Reminder is stored in Persistence, so it survives a restart.
+type Reminder struct {
+	Id      string    `json:"id"`
+	At      time.Time `json:"at"`
+	Channel string    `json:"channel"`
+	Thread  string    `json:"thread"`
+	User    string    `json:"user"`
+	Text    string    `json:"text"`
+	// Direct makes the reminder to be sent as the direct message.
+	Direct bool `json:"direct"`
+	// Permalink is the link to the message which requested the reminder.
+	Permalink string `json:"permalink"`
+}
+
+// RegisterRemindCommand registers the "remind" command.
+//
+//	remind me in 30m to check the deploy
+//	remind me tomorrow 9:00 standup
+//	remind --dm me at 15:00 on friday submit the report
+func (bot *Bot) RegisterRemindCommand(opts ...CommandOption) {
+	bot.CommandWithArgv("remind", "remind [--dm] me <in 30m|tomorrow 9:00|at 15:00 on friday> <text>", func(event *Event) {
+		m := remindTargetPattern.FindStringSubmatch(strings.Join(event.Argv, " "))
+		if m == nil {
+			event.Reply(ErrUnknownReminderTime.Error())
+			return
+		}
+		at, text, err := ParseReminderTime(m[2], bot.clock.Now())
+		if err != nil {
+			event.Reply(err.Error())
+			return
+		}
+
+		reminder := &Reminder{
+			At:        at,
+			Channel:   event.Channel,
+			User:      event.User.Id,
+			Text:      text,
+			Direct:    m[1] != "",
+			Permalink: event.Permalink(),
+		}
+		if event.inThread || event.ThreadTs != "" {
+			reminder.Thread = event.ThreadRoot()
+		}
+		if err := bot.scheduler.Remind(reminder); err != nil {
+			event.Reply(err.Error())
+			return
+		}
+		event.Reply(fmt.Sprintf("I will remind you at %s", at.Format("2006-01-02 15:04 MST")))
+	}, opts...)
+}
+
+// ParseReminderTime parses the time at the beginning of text and returns the time and the rest of text.
+// The relative time and the time of day are resolved from now.
+func ParseReminderTime(text string, now time.Time) (time.Time, string, error) {
+	text = strings.TrimSpace(text)
+
+	if m := remindInGoPattern.FindStringSubmatch(text); m != nil {
+		d, err := time.ParseDuration(m[1])
+		if err != nil {
+			return time.Time{}, "", err
+		}
+		return now.Add(d), m[2], nil
+	}
+	if m := remindInPattern.FindStringSubmatch(text); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		var unit time.Duration
+		switch strings.ToLower(m[2])[0] {
+		case 's':
+			unit = time.Second
+		case 'm':
+			unit = time.Minute
+		case 'h':
+			unit = time.Hour
+		case 'd':
+			return now.AddDate(0, 0, n), m[3], nil
+		}
+		return now.Add(time.Duration(n) * unit), m[3], nil
+	}
+	if m := remindAtPattern.FindStringSubmatch(text); m != nil {
+		at, err := resolveDay(now, m[2], m[1])
+		return at, m[3], err
+	}
+	if m := remindOnDayPattern.FindStringSubmatch(text); m != nil {
+		at, err := resolveDay(now, m[1], m[2])
+		return at, m[3], err
+	}
+
+	return time.Time{}, "", ErrUnknownReminderTime
+}
+
+// resolveDay returns the first time of clock on day after now.
+// If day is empty, the time is today or tomorrow.
+func resolveDay(now time.Time, day, clock string) (time.Time, error) {
+	c := strings.SplitN(clock, ":", 2)
+	hour, _ := strconv.Atoi(c[0])
+	minute, _ := strconv.Atoi(c[1])
+	if hour > 23 || minute > 59 {
+		return time.Time{}, ErrUnknownReminderTime
+	}
+	at := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+
+	day = strings.ToLower(day)
+	switch day {
+	case "":
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+	case "today":
+		if !at.After(now) {
+			return time.Time{}, errors.New("the time has already passed")
+		}
+	case "tomorrow":
+		at = at.AddDate(0, 0, 1)
+	default:
+		weekday, ok := weekdayNames[day[:3]]
+		if !ok {
+			return time.Time{}, ErrUnknownReminderTime
+		}
+		diff := (weekday - int(now.Weekday()) + 7) % 7
+		at = at.AddDate(0, 0, diff)
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 7)
+		}
+	}
+
+	return at, nil
+}
+
+func (reminder *Reminder) entry() *ScheduleEntry {
+	return &ScheduleEntry{
+		Id:          reminder.Id,
+		Channel:     reminder.Channel,
+		Description: fmt.Sprintf("reminder for <@%s> at %s %q", reminder.User, reminder.At.Format("2006-01-02 15:04 MST"), reminder.Text),
+		schedule:    &onceSchedule{at: reminder.At},
+		next:        reminder.At,
+		reminder:    reminder,
+		f:           reminder.deliver,
+	}
+}
+
+func (reminder *Reminder) deliver(event *Event) {
+	event.User = User{Id: reminder.User}
+	event.ThreadTs = reminder.Thread
+	event.inThread = reminder.Thread != ""
+
+	text := fmt.Sprintf("reminder: %s", reminder.Text)
+	if reminder.Permalink != "" {
+		text += "\n" + reminder.Permalink
+	}
+	if reminder.Direct {
+		event.Direct(text)
+		return
+	}
+	event.Reply(text)
+}
@@ -0,0 +1,191 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Replay runs the bot which uses TestConnector with the events recorded by RecordingConnector
+// and returns the messages which are sent by the bot.
+// Each event is handled synchronously, and the messages which are sent until the handler returns
+// are regarded as the reply to the event.
+//
+// The ids of the messages sent in the recording are translated to the ones assigned by TestConnector,
+// so that the reactions to the confirmation messages are replayed.
+// The message of the replay is paired with the recorded reply to the same event which has the same kind and text.
+func Replay(bot *Bot, connector *TestConnector, r io.Reader) ([]RecordEntry, error) {
+	records, err := ReadRecords(r)
+	if err != nil {
+		return nil, err
+	}
+
+	bot.ctx, bot.cancel = context.WithCancel(context.Background())
+	defer bot.cancel()
+	if err := bot.dialogs.Restore(); err != nil {
+		return nil, err
+	}
+	if err := bot.interactions.Restore(); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]string)
+	result := make([]RecordEntry, 0)
+	for i, entry := range records {
+		if entry.Kind != RecordEvent || entry.Event == nil {
+			continue
+		}
+		event := entry.Event.toEvent()
+		if id, ok := ids[event.Ts]; ok {
+			event.Ts = id
+		}
+		if id, ok := ids[event.ThreadTs]; ok {
+			event.ThreadTs = id
+		}
+		event.Bot = bot
+		bot.eventHandler.Handle(event, false)
+		bot.eventHandler.settle()
+
+		replies := recordedReplies(records[i+1:])
+		for {
+			m, err := connector.Await(0)
+			if err != nil {
+				break
+			}
+			replayed := newRecordEntry(m)
+			if recorded := pairReply(replies, replayed); recorded != nil && recorded.Ts != "" {
+				ids[recorded.Ts] = m.Ts
+			}
+			result = append(result, replayed)
+		}
+	}
+
+	return result, nil
+}
+
+// recordedReplies returns the messages which are sent before the next event.
+func recordedReplies(records []RecordEntry) []*RecordEntry {
+	replies := make([]*RecordEntry, 0)
+	for i := range records {
+		if records[i].Kind == RecordEvent {
+			break
+		}
+		replies = append(replies, &records[i])
+	}
+
+	return replies
+}
+
+// pairReply returns the recorded reply which is the same message as the replayed one and removes it from replies.
+func pairReply(replies []*RecordEntry, replayed RecordEntry) *RecordEntry {
+	for i, recorded := range replies {
+		if recorded == nil || recorded.Kind != replayed.Kind || recorded.Text != replayed.Text {
+			continue
+		}
+		replies[i] = nil
+		return recorded
+	}
+
+	return nil
+}
+
+// CompareGolden compares the entries with the golden file and returns the difference.
+// The empty string is returned if they are same.
+// If update is true, the golden file is overwritten by the entries instead.
+func CompareGolden(path string, entries []RecordEntry, update bool) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := WriteRecords(buf, entries); err != nil {
+		return "", err
+	}
+	if update {
+		return "", ioutil.WriteFile(path, buf.Bytes(), 0644)
+	}
+
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return diffLines(string(golden), buf.String()), nil
+}
+
+func newRecordEntry(m *TestMessage) RecordEntry {
+	entry := RecordEntry{Channel: m.Channel, ThreadTs: m.ThreadTs, Text: m.Text}
+	switch {
+	case m.Response:
+		entry.Kind = RecordRespond
+		entry.User = m.User
+	case m.Ephemeral:
+		entry.Kind = RecordSendEphemeral
+		entry.User = m.User
+	case m.Edited:
+		entry.Kind = RecordUpdate
+	case m.Deleted:
+		entry.Kind = RecordDelete
+	case m.Direct:
+		entry.Kind = RecordSendPrivate
+		entry.Channel = ""
+		entry.User = m.User
+	case m.Attachment != nil:
+		entry.Kind = RecordAttach
+		entry.FileName = m.Attachment.FileName
+	case m.Confirm:
+		entry.Kind = RecordSendWithConfirm
+	case m.Rich != nil:
+		entry.Kind = RecordSendRich
+	case m.ThreadTs != "":
+		entry.Kind = RecordSendInThread
+	default:
+		entry.Kind = RecordSend
+	}
+
+	return entry
+}
+
+// diffLines returns the difference of the lines by the longest common subsequence.
+// The removed lines are prefixed by "-" and the added lines are prefixed by "+".
+func diffLines(a, b string) string {
+	if a == b {
+		return ""
+	}
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			switch {
+			case x[i] == y[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			diff.WriteString(" " + x[i] + "\n")
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			diff.WriteString("-" + x[i] + "\n")
+			i++
+		default:
+			diff.WriteString("+" + y[j] + "\n")
+			j++
+		}
+	}
+
+	return diff.String()
+}
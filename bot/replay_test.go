@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"github.com/f110/montegrappa/persistence"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files")
+
+func registerReplayCommands(b *Bot) {
+	b.Command("ping", "", func(event *Event) {
+		event.Say("pong")
+	})
+	b.Command("deploy", "", func(event *Event) {
+		event.SayWithConfirm("deploy prod?", "ok", func(e *Event) {
+			e.Say("deployed")
+		})
+	})
+	b.Command("secret", "", func(event *Event) {
+		event.Direct("hunter2")
+		event.Whisper("sent")
+	})
+}
+
+func TestReplay(t *testing.T) {
+	// Record the session.
+	recorded := new(bytes.Buffer)
+	inner := NewTestConnector()
+	recorder := NewRecordingConnector(inner, recorded)
+	b := NewBot(recorder, persistence.NewMemoryDB(), "bot", nil, nil)
+	registerReplayCommands(b)
+	ctx, cancel := context.WithCancel(context.Background())
+	go b.Start(ctx)
+
+	transcript := inner.Transcript(t, "C1")
+	transcript.Expect("U1", "bot ping").Reply("pong")
+	transcript.Expect("U1", "bot deploy").Reply("deploy prod?")
+	confirm := inner.Messages()[1]
+	// The reaction is retried because the confirmation is registered asynchronously.
+	for i := 0; ; i++ {
+		inner.Reaction("C1", confirm.Ts, "U1", "ok")
+		m, err := inner.Await(testNoReplyWait)
+		if err == nil && m.Text == "deployed" {
+			break
+		}
+		if i == 10 {
+			t.Fatalf("the confirmation is not handled in the recording: %v, %v", m, err)
+		}
+	}
+	transcript.Expect("U1", "bot secret").Reply("hunter2", "sent")
+	cancel()
+
+	// Replay it by the new bot.
+	connector := NewTestConnector()
+	replayBot := NewBot(connector, persistence.NewMemoryDB(), "bot", nil, nil)
+	registerReplayCommands(replayBot)
+	entries, err := Replay(replayBot, connector, bytes.NewReader(recorded.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := connector.Messages()
+	if replayed[1].Ts == confirm.Ts {
+		t.Fatalf("the id of the confirmation is not changed by the replay: %s", confirm.Ts)
+	}
+	if len(entries) != 5 || entries[2].Text != "deployed" {
+		t.Fatalf("the reaction to the confirmation is not replayed: %+v", entries)
+	}
+
+	diff, err := CompareGolden(filepath.Join("testdata", "replay.golden"), entries, *updateGolden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != "" {
+		t.Fatalf("the replay differs from the golden file:\n%s", diff)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	cases := []struct {
+		A    string
+		B    string
+		Diff string
+	}{
+		{A: "a\nb\n", B: "a\nb\n", Diff: ""},
+		{A: "a\nb\nc\n", B: "a\nc\n", Diff: " a\n-b\n c\n"},
+		{A: "a\nc\n", B: "a\nb\nc\n", Diff: " a\n+b\n c\n"},
+		{A: "a\nb\n", B: "a\nx\n", Diff: " a\n-b\n+x\n"},
+		{A: "", B: "a\n", Diff: "-\n+a\n"},
+	}
+
+	for _, c := range cases {
+		if diff := diffLines(c.A, c.B); diff != c.Diff {
+			t.Errorf("%q and %q: expected %q but got %q", c.A, c.B, c.Diff, diff)
+		}
+	}
+}
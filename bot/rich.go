@@ -0,0 +1,185 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The styles of Button.
+const (
+	ButtonDefault = ""
+	ButtonPrimary = "primary"
+	ButtonDanger  = "danger"
+)
+
+// RichMessage is the message which has the layout.
+// The model doesn't depend on any chat service, and each Connector renders it to its own format.
+// The connector which doesn't support the layout sends PlainText instead.
+//
+//	event.SayRich(&bot.RichMessage{
+//		Text: "deploy prod?",
+//		Blocks: []bot.Block{
+//			&bot.Section{Text: "*deploy* prod", Fields: []string{"*branch*\nmaster", "*by*\n@alice"}},
+//			&bot.Divider{},
+//			&bot.Actions{Elements: []bot.Element{&bot.Button{ActionId: "approve", Text: "Approve", Style: bot.ButtonPrimary}}},
+//		},
+//	})
+type RichMessage struct {
+	// Text is used for the notification and as the fallback of the layout.
+	Text        string
+	Blocks      []Block
+	Attachments []*RichAttachment
+}
+
+// RichAttachment is the group of blocks which is shown with the colored bar.
+type RichAttachment struct {
+	// Color is "good", "warning", "danger" or the hex color code like "#439FE0".
+	Color  string
+	Blocks []Block
+}
+
+// Block is a part of the layout of RichMessage.
+type Block interface {
+	block()
+}
+
+// Element is the component which is placed in Actions or Section.Accessory.
+type Element interface {
+	element()
+}
+
+// Section is the text with the optional fields and the accessory.
+// Text and Fields are formatted as markdown.
+type Section struct {
+	BlockId   string
+	Text      string
+	Fields    []string
+	Accessory Element
+}
+
+// Divider is the horizontal line.
+type Divider struct{}
+
+// Image is the image which is shown as a block or as the accessory of Section.
+type Image struct {
+	BlockId string
+	URL     string
+	AltText string
+	Title   string
+}
+
+// Context is the small text which is shown under the other blocks.
+type Context struct {
+	BlockId  string
+	Elements []string
+}
+
+// Actions is the row of the interactive elements.
+type Actions struct {
+	BlockId  string
+	Elements []Element
+}
+
+// Button is clicked by the user. If URL is set, the button opens the URL.
+type Button struct {
+	ActionId string
+	Text     string
+	Value    string
+	Style    string
+	URL      string
+}
+
+// Select is the menu which the user chooses one of Options.
+type Select struct {
+	ActionId    string
+	Placeholder string
+	Options     []SelectOption
+	// Initial is the value of the option which is selected at first.
+	Initial string
+}
+
+type SelectOption struct {
+	Text  string
+	Value string
+}
+
+func (*Section) block() {}
+func (*Divider) block() {}
+func (*Image) block()   {}
+func (*Context) block() {}
+func (*Actions) block() {}
+
+func (*Image) element()  {}
+func (*Button) element() {}
+func (*Select) element() {}
+
+// PlainText renders the message as the plain text for the connector which doesn't support the layout.
+func (m *RichMessage) PlainText() string {
+	lines := make([]string, 0)
+	if m.Text != "" {
+		lines = append(lines, m.Text)
+	}
+	lines = append(lines, plainTextBlocks(m.Blocks)...)
+	for _, a := range m.Attachments {
+		for _, line := range plainTextBlocks(a.Blocks) {
+			lines = append(lines, "| "+line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func plainTextBlocks(blocks []Block) []string {
+	lines := make([]string, 0)
+	for _, b := range blocks {
+		switch b := b.(type) {
+		case *Section:
+			if b.Text != "" {
+				lines = append(lines, b.Text)
+			}
+			for _, f := range b.Fields {
+				lines = append(lines, "  "+strings.Replace(f, "\n", ": ", 1))
+			}
+			if b.Accessory != nil {
+				lines = append(lines, plainTextElement(b.Accessory))
+			}
+		case *Divider:
+			lines = append(lines, "----")
+		case *Image:
+			lines = append(lines, plainTextElement(b))
+		case *Context:
+			lines = append(lines, strings.Join(b.Elements, " | "))
+		case *Actions:
+			elements := make([]string, 0, len(b.Elements))
+			for _, e := range b.Elements {
+				elements = append(elements, plainTextElement(e))
+			}
+			lines = append(lines, strings.Join(elements, " "))
+		}
+	}
+
+	return lines
+}
+
+func plainTextElement(e Element) string {
+	switch e := e.(type) {
+	case *Image:
+		if e.Title != "" {
+			return fmt.Sprintf("%s <%s>", e.Title, e.URL)
+		}
+		return "<" + e.URL + ">"
+	case *Button:
+		if e.URL != "" {
+			return fmt.Sprintf("[%s <%s>]", e.Text, e.URL)
+		}
+		return "[" + e.Text + "]"
+	case *Select:
+		options := make([]string, 0, len(e.Options))
+		for _, o := range e.Options {
+			options = append(options, o.Text)
+		}
+		return fmt.Sprintf("[%s: %s]", e.Placeholder, strings.Join(options, " / "))
+	}
+
+	return ""
+}
@@ -0,0 +1,237 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	roleTableName = "roles"
+)
+
+var (
+	// UserGroupMembersTTL is the duration for which the members of the user group are cached.
+	UserGroupMembersTTL = 5 * time.Minute
+
+	ErrRoleNotFound = errors.New("role not found")
+)
+
+// Role is a set of users who are allowed to run the commands which require the role.
+type Role struct {
+	Name       string   `json:"name"`
+	Users      []string `json:"users"`
+	UserGroups []string `json:"user_groups"`
+}
+
+// UserGroupResolver is implemented by the connector which can retrieve members of the user group.
+type UserGroupResolver interface {
+	GetUserGroupMembers(string) ([]string, error)
+}
+
+// RoleManager manages roles and their members.
+// The roles are stored through Persistence, so assignments survive a restart.
+// The members of the user groups are cached for UserGroupMembersTTL.
+type RoleManager struct {
+	persistence Persistence
+	connector   Connector
+	clock       Clock
+	roles       map[string]*Role
+	members     map[string]*userGroupMembers
+	mutex       sync.RWMutex
+}
+
+// userGroupMembers is the cached members of the user group.
+type userGroupMembers struct {
+	users     []string
+	expiresAt time.Time
+}
+
+func NewRoleManager(persistence Persistence, connector Connector) *RoleManager {
+	return &RoleManager{
+		persistence: persistence,
+		connector:   connector,
+		clock:       SystemClock,
+		roles:       make(map[string]*Role),
+		members:     make(map[string]*userGroupMembers),
+	}
+}
+
+// DefineRole declares the role.
+// If the role is already stored in Persistence, the stored members are loaded.
+func (m *RoleManager) DefineRole(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.roles[name]; ok {
+		return nil
+	}
+
+	role := &Role{Name: name}
+	if buf, err := m.persistence.Get(roleTableName, name); err == nil && buf != nil {
+		if err := json.Unmarshal(buf, role); err != nil {
+			return err
+		}
+	}
+	m.roles[name] = role
+
+	return m.save(role)
+}
+
+func (m *RoleManager) Roles() []Role {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	roles := make([]Role, 0, len(m.roles))
+	for _, r := range m.roles {
+		roles = append(roles, *r)
+	}
+
+	return roles
+}
+
+func (m *RoleManager) AddUser(role, userId string) error {
+	return m.update(role, func(r *Role) {
+		r.Users = appendUnique(r.Users, userId)
+	})
+}
+
+func (m *RoleManager) RemoveUser(role, userId string) error {
+	return m.update(role, func(r *Role) {
+		r.Users = remove(r.Users, userId)
+	})
+}
+
+func (m *RoleManager) AddUserGroup(role, groupId string) error {
+	return m.update(role, func(r *Role) {
+		r.UserGroups = appendUnique(r.UserGroups, groupId)
+	})
+}
+
+func (m *RoleManager) RemoveUserGroup(role, groupId string) error {
+	return m.update(role, func(r *Role) {
+		r.UserGroups = remove(r.UserGroups, groupId)
+	})
+}
+
+// HasRole reports whether the user is a member of the role directly or through the user group.
+func (m *RoleManager) HasRole(userId, role string) (bool, error) {
+	m.mutex.RLock()
+	r, ok := m.roles[role]
+	if !ok {
+		m.mutex.RUnlock()
+		return false, ErrRoleNotFound
+	}
+	users := append([]string{}, r.Users...)
+	groups := append([]string{}, r.UserGroups...)
+	m.mutex.RUnlock()
+
+	for _, u := range users {
+		if u == userId {
+			return true, nil
+		}
+	}
+	if len(groups) == 0 {
+		return false, nil
+	}
+
+	resolver, ok := m.connector.(UserGroupResolver)
+	if !ok {
+		return false, nil
+	}
+	for _, g := range groups {
+		members, err := m.userGroupMembers(resolver, g)
+		if err != nil {
+			return false, err
+		}
+		for _, u := range members {
+			if u == userId {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// Authorize reports whether the user has any of roles.
+func (m *RoleManager) Authorize(userId string, roles []string) bool {
+	for _, role := range roles {
+		ok, err := m.HasRole(userId, role)
+		if err != nil {
+			log.Printf("failed to check role %s: %s", role, err)
+			continue
+		}
+		if ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// userGroupMembers returns the members of the user group from the cache or the resolver.
+func (m *RoleManager) userGroupMembers(resolver UserGroupResolver, groupId string) ([]string, error) {
+	now := m.clock.Now()
+	m.mutex.RLock()
+	cached, ok := m.members[groupId]
+	m.mutex.RUnlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.users, nil
+	}
+
+	users, err := resolver.GetUserGroupMembers(groupId)
+	if err != nil {
+		return nil, err
+	}
+	m.mutex.Lock()
+	m.members[groupId] = &userGroupMembers{users: users, expiresAt: now.Add(UserGroupMembersTTL)}
+	m.mutex.Unlock()
+
+	return users, nil
+}
+
+func (m *RoleManager) update(role string, f func(*Role)) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	r, ok := m.roles[role]
+	if !ok {
+		return ErrRoleNotFound
+	}
+	f(r)
+
+	return m.save(r)
+}
+
+func (m *RoleManager) save(role *Role) error {
+	buf, err := json.Marshal(role)
+	if err != nil {
+		return err
+	}
+
+	return m.persistence.Set(roleTableName, role.Name, buf)
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, e := range s {
+		if e == v {
+			return s
+		}
+	}
+
+	return append(s, v)
+}
+
+func remove(s []string, v string) []string {
+	n := make([]string, 0, len(s))
+	for _, e := range s {
+		if e != v {
+			n = append(n, e)
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/f110/montegrappa/persistence"
+)
+
+// userGroupConnector is TestConnector which resolves the members of the user groups.
+type userGroupConnector struct {
+	*TestConnector
+
+	groups map[string][]string
+	err    error
+	calls  int
+	mutex  sync.Mutex
+}
+
+func (c *userGroupConnector) GetUserGroupMembers(groupId string) ([]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.groups[groupId], nil
+}
+
+// lockedBuffer is the buffer which can be written by the logger in the other goroutines.
+type lockedBuffer struct {
+	buf   bytes.Buffer
+	mutex sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buf.String()
+}
+
+func TestRoleManager_HasRole(t *testing.T) {
+	connector := &userGroupConnector{TestConnector: NewTestConnector(), groups: map[string][]string{"S1": {"U2"}}}
+	clock := NewTestClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	m := NewRoleManager(persistence.NewMemoryDB(), connector)
+	m.clock = clock
+	if err := m.DefineRole("admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddUser("admin", "U1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddUserGroup("admin", "S1"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		User string
+		Has  bool
+	}{
+		{User: "U1", Has: true},
+		{User: "U2", Has: true},
+		{User: "U3", Has: false},
+	}
+	for _, c := range cases {
+		if has, err := m.HasRole(c.User, "admin"); err != nil || has != c.Has {
+			t.Errorf("%s: expected %v but got %v, %v", c.User, c.Has, has, err)
+		}
+	}
+	if _, err := m.HasRole("U1", "unknown"); err != ErrRoleNotFound {
+		t.Fatalf("expected ErrRoleNotFound: %v", err)
+	}
+
+	// The members of the user group are cached until UserGroupMembersTTL passes.
+	if connector.calls != 1 {
+		t.Fatalf("the members are not cached: %d calls", connector.calls)
+	}
+	connector.groups["S1"] = []string{"U3"}
+	if has, _ := m.HasRole("U3", "admin"); has {
+		t.Fatal("the cached members are not used")
+	}
+	clock.Advance(UserGroupMembersTTL)
+	if has, _ := m.HasRole("U3", "admin"); !has {
+		t.Fatal("the members are not retrieved again after the TTL")
+	}
+
+	// The failure is not cached.
+	clock.Advance(UserGroupMembersTTL)
+	connector.err = errors.New("ratelimited")
+	if _, err := m.HasRole("U3", "admin"); err == nil {
+		t.Fatal("expected the error of the resolver")
+	}
+	connector.err = nil
+	if has, err := m.HasRole("U3", "admin"); err != nil || !has {
+		t.Fatalf("the members are not retrieved after the failure: %v", err)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	logs := &lockedBuffer{}
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+
+	b, connector := newTestBot()
+	if err := b.Roles().DefineRole("admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Roles().AddUser("admin", "U1"); err != nil {
+		t.Fatal(err)
+	}
+	stop := runTestBot(b)
+	defer stop()
+	b.Command("deploy", "deploy the application", func(event *Event) {
+		event.Say("deploying")
+	}, RequireRole("admin"))
+
+	transcript := connector.Transcript(t, "C1")
+	transcript.Expect("U1", "bot deploy").Reply("deploying")
+	transcript.Expect("U2", "bot deploy").Reply("you are not allowed to run this command")
+
+	denied := connector.Messages()[1]
+	if !denied.Ephemeral || denied.User != "U2" {
+		t.Fatalf("the denial is not shown only to the user: %+v", denied)
+	}
+	if !strings.Contains(logs.String(), `audit: denied user=U2 channel=C1 message="bot deploy" required_roles=[admin]`) {
+		t.Fatalf("the denial is not logged: %s", logs.String())
+	}
+	if strings.Contains(logs.String(), "user=U1") {
+		t.Fatalf("the allowed command is logged as denied: %s", logs.String())
+	}
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RegisterScheduleCommands registers the "schedule" commands for managing recurring messages from the chat.
+// The commands operate only on the messages which are scheduled by ScheduleMessage,
+// so the entries registered in code and the reminders can't be changed from the chat.
+// The options are applied to all subcommands, e.g. RequireRole("admin").
+func (bot *Bot) RegisterScheduleCommands(opts ...CommandOption) *CommandGroup {
+	group := bot.Group("schedule", "manage recurring messages", opts...)
+
+	group.Command("list", "list schedules", func(event *Event) {
+		entries := bot.scheduler.ScheduledMessages()
+		if len(entries) == 0 {
+			event.Say("no schedule")
+			return
+		}
+
+		lines := make([]string, 0, len(entries))
+		for _, e := range entries {
+			status := "next: " + e.Next().Format("2006-01-02 15:04 MST")
+			if e.Paused() {
+				status = "paused"
+			}
+			lines = append(lines, fmt.Sprintf("%s - %s in <#%s> (%s)", e.Id, e.Description, e.Channel, status))
+		}
+		event.Say(strings.Join(lines, "\n"))
+	})
+
+	group.Command("add", "add the recurring message", func(event *Event) {
+		tz, err := time.LoadLocation(event.Args.String("tz"))
+		if err != nil {
+			event.Reply(fmt.Sprintf("unknown time zone: %s", event.Args.String("tz")))
+			return
+		}
+
+		message, err := bot.scheduler.ScheduleMessage(event.Args.String("spec"), tz, event.Args.Channel("channel").Id, event.Args.String("text"), event.User.Id)
+		if err != nil {
+			event.Reply(err.Error())
+			return
+		}
+		event.Reply(fmt.Sprintf("scheduled: %s", message.Id))
+	}, Args(
+		ArgSpec{Name: "spec", Required: true, Description: "cron expression like \"0 9 * * 1-5\""},
+		ArgSpec{Name: "channel", Type: ChannelArg, Required: true},
+		ArgSpec{Name: "text", Required: true, Rest: true},
+		ArgSpec{Name: "tz", Flag: true, Default: "Local", Description: "time zone like Asia/Tokyo"},
+	))
+
+	group.Command("delete", "delete the schedule", func(event *Event) {
+		bot.operateScheduledMessage(event, bot.scheduler.Remove, "deleted")
+	}, Args(ArgSpec{Name: "id", Required: true}))
+
+	group.Command("pause", "pause the schedule", func(event *Event) {
+		bot.operateScheduledMessage(event, bot.scheduler.Pause, "paused")
+	}, Args(ArgSpec{Name: "id", Required: true}))
+
+	group.Command("resume", "resume the schedule", func(event *Event) {
+		bot.operateScheduledMessage(event, bot.scheduler.Resume, "resumed")
+	}, Args(ArgSpec{Name: "id", Required: true}))
+
+	return group
+}
+
+// operateScheduledMessage operates the scheduled message of the id in the arguments and replies the result.
+func (bot *Bot) operateScheduledMessage(event *Event, operate func(string) error, done string) {
+	id := event.Args.String("id")
+	if !bot.scheduler.isScheduledMessage(id) {
+		event.Reply(ErrEntryNotFound.Error())
+		return
+	}
+	if err := operate(id); err != nil {
+		event.Reply(err.Error())
+		return
+	}
+
+	event.Reply(fmt.Sprintf("%s: %s", done, id))
+}
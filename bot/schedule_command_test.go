@@ -0,0 +1,126 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/f110/montegrappa/persistence"
+)
+
+func TestNewEntryId(t *testing.T) {
+	ids := make(chan string, 1000)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ids <- newEntryId()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[string]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("duplicated id: %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestScheduler_Restore(t *testing.T) {
+	db := persistence.NewMemoryDB()
+	next := testSchedulerStart.Add(time.Hour)
+	messages := []ScheduledMessage{
+		{Id: "a", Spec: "0 9 * * *", TimeZone: "UTC", Channel: "C1", Text: "good morning", Next: next},
+		{Id: "b", Spec: "0 18 * * *", TimeZone: "UTC", Channel: "C1", Text: "good night", Paused: true},
+		{Id: "c", Spec: "0 9 * * *", TimeZone: "Unknown/Zone", Channel: "C1", Text: "broken"},
+	}
+	for _, m := range messages {
+		buf, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Set(scheduleTableName, m.Id, buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scheduler := NewScheduler()
+	scheduler.persistence = db
+	scheduler.SetClock(NewTestClock(testSchedulerStart))
+	if err := scheduler.Restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := make(map[string]*ScheduleEntry)
+	for _, e := range scheduler.ScheduledMessages() {
+		e := e
+		entries[e.Id] = &e
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 schedules: %v", entries)
+	}
+	if !entries["a"].Next().Equal(next) || entries["a"].Paused() {
+		t.Fatalf("the stored activation time is not restored: %s", entries["a"].Next())
+	}
+	if !entries["b"].Paused() {
+		t.Fatal("the paused schedule is resumed by restoring")
+	}
+}
+
+func TestRegisterScheduleCommands(t *testing.T) {
+	b, connector, stop := startTestBot()
+	defer stop()
+	b.RegisterScheduleCommands()
+	b.scheduler.Every(time.Hour, "C1", func(_ *Event) {})
+
+	transcript := connector.Transcript(t, "C1")
+	transcript.Expect("U1", "bot schedule list").Reply("no schedule")
+	transcript.Expect("U1", "bot schedule add \"0 9 * * 1-5\" <#C2|random> don't forget\nthe  standup --tz=Asia/Tokyo").
+		ReplyMatch("^<@U1>: scheduled: [0-9a-z]+$")
+
+	entries := b.scheduler.ScheduledMessages()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 schedule: %d", len(entries))
+	}
+	id := entries[0].Id
+	message := entries[0].message
+	if message.Spec != "0 9 * * 1-5" || message.TimeZone != "Asia/Tokyo" || message.Channel != "C2" || message.CreatedBy != "U1" {
+		t.Fatalf("unexpected schedule: %+v", message)
+	}
+	if message.Text != "don't forget\nthe  standup" {
+		t.Fatalf("the text is changed: %q", message.Text)
+	}
+	if buf, err := b.Persistence.Get(scheduleTableName, id); err != nil || buf == nil {
+		t.Fatalf("the schedule is not stored: %v", err)
+	}
+
+	transcript.Expect("U1", "bot schedule list").ReplyMatch(fmt.Sprintf(`^%s - 0 9 \* \* 1-5 \(Asia/Tokyo\) .+ in <#C2> \(next: .+\)$`, id))
+	transcript.Expect("U1", "bot schedule pause "+id).Reply("<@U1>: paused: " + id)
+	transcript.Expect("U1", "bot schedule list").ReplyMatch(`\(paused\)$`)
+	transcript.Expect("U1", "bot schedule resume "+id).Reply("<@U1>: resumed: " + id)
+	transcript.Expect("U1", "bot schedule list").ReplyMatch(`\(next: .+\)$`)
+
+	// The entry registered in code can't be operated from the chat.
+	other := b.scheduler.Entries()[0].Id
+	if other == id {
+		other = b.scheduler.Entries()[1].Id
+	}
+	transcript.Expect("U1", "bot schedule delete "+other).Reply("<@U1>: " + ErrEntryNotFound.Error())
+
+	transcript.Expect("U1", "bot schedule delete "+id).Reply("<@U1>: deleted: " + id)
+	transcript.Expect("U1", "bot schedule list").Reply("no schedule")
+	if buf, _ := b.Persistence.Get(scheduleTableName, id); buf != nil {
+		t.Fatal("the schedule is not deleted from the persistence")
+	}
+
+	transcript.Expect("U1", "bot schedule add \"0 9 * * *\" <#C2|random> hello --tz=Unknown/Zone").Reply("<@U1>: unknown time zone: Unknown/Zone")
+	transcript.Expect("U1", "bot schedule add \"0 0 30 2 *\" <#C2|random> hello --tz=UTC").ReplyMatch("^<@U1>: cron: never activated")
+}
@@ -2,16 +2,35 @@ package bot
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	interval = 1 * time.Minute
+
+	scheduleTableName = "schedules"
+	reminderTableName = "reminders"
 )
 
 var (
+	// MisfireThreshold is the delay after which the activation is treated as missed.
+	MisfireThreshold = 2 * interval
+	// MaxCatchUpRuns limits the number of the missed activations which are executed by MisfireRunAll.
+	MaxCatchUpRuns = 100
+
+	ErrInvalidInterval = errors.New("scheduler: interval must be positive")
+	// ErrIntervalLessThanMinute is no longer returned.
+	//
+	// Deprecated: Every accepts any positive interval and returns ErrInvalidInterval otherwise.
 	ErrIntervalLessThanMinute = errors.New("scheduler: interval must not be less than 1 minute")
+	ErrEntryNotFound          = errors.New("scheduler: entry not found")
 )
 
 type UnitTime int
@@ -23,44 +42,139 @@ const (
 
 type ScheduleFunc func(event *Event)
 
+// MisfirePolicy decides how the activations which are missed while the bot is down or disconnected are handled.
+type MisfirePolicy int
+
+const (
+	// MisfireRunOnce executes the missed activations only once.
+	MisfireRunOnce MisfirePolicy = iota
+	// MisfireSkip drops the missed activations and waits for the next one.
+	MisfireSkip
+	// MisfireRunAll executes every missed activation up to MaxCatchUpRuns.
+	MisfireRunAll
+)
+
+// ConcurrencyPolicy decides what happens when the entry is activated while the previous run is in progress.
+type ConcurrencyPolicy int
+
+const (
+	// ConcurrencyAllow runs the entries in parallel.
+	ConcurrencyAllow ConcurrencyPolicy = iota
+	// ConcurrencySkip drops the activation if the previous run is in progress.
+	ConcurrencySkip
+	// ConcurrencyQueue waits for the previous run to finish.
+	ConcurrencyQueue
+)
+
+// ScheduleOption configures the entry.
+type ScheduleOption func(*ScheduleEntry)
+
+// Misfire sets the misfire policy. The default is MisfireRunOnce.
+func Misfire(policy MisfirePolicy) ScheduleOption {
+	return func(entry *ScheduleEntry) {
+		entry.misfire = policy
+	}
+}
+
+// Concurrency sets the concurrency policy. The default is ConcurrencyAllow.
+func Concurrency(policy ConcurrencyPolicy) ScheduleOption {
+	return func(entry *ScheduleEntry) {
+		entry.concurrency = policy
+	}
+}
+
 type ScheduleEntry struct {
-	Channel string
+	Id          string
+	Channel     string
+	Description string
 
-	interval time.Duration
-	next     time.Time
+	schedule    Schedule
+	next        time.Time
+	paused      bool
+	misfire     MisfirePolicy
+	concurrency ConcurrencyPolicy
+	// running is the semaphore for ConcurrencySkip and ConcurrencyQueue.
+	running  chan struct{}
+	message  *ScheduledMessage
+	reminder *Reminder
 	f        ScheduleFunc
 }
 
+// ScheduledMessage is the recurring message which is stored in Persistence.
+// Unlike the entry registered in code, it can be created by chat commands and survives a restart.
+type ScheduledMessage struct {
+	Id        string `json:"id"`
+	Spec      string `json:"spec"`
+	TimeZone  string `json:"time_zone"`
+	Channel   string `json:"channel"`
+	Text      string `json:"text"`
+	Paused    bool   `json:"paused"`
+	CreatedBy string `json:"created_by"`
+	// Next is the next activation time, which is used for detecting the activation missed while the bot is down.
+	Next time.Time `json:"next,omitempty"`
+}
+
+// intervalSchedule activates the entry at the fixed interval.
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+// onceSchedule activates the entry only once.
+type onceSchedule struct {
+	at time.Time
+}
+
 type Scheduler struct {
-	entries   []*ScheduleEntry
-	ctx       context.Context
-	cancel    context.CancelFunc
-	eventChan chan *ScheduleEntry
+	entries     []*ScheduleEntry
+	persistence Persistence
+	clock       Clock
+	ctx         context.Context
+	cancel      context.CancelFunc
+	eventChan   chan *ScheduleEntry
+	wakeup      chan struct{}
+	mutex       sync.RWMutex
 }
 
 func NewScheduler() *Scheduler {
-	return &Scheduler{entries: make([]*ScheduleEntry, 0), eventChan: make(chan *ScheduleEntry)}
+	return &Scheduler{
+		entries:     make([]*ScheduleEntry, 0),
+		persistence: &NoneDB{},
+		clock:       SystemClock,
+		eventChan:   make(chan *ScheduleEntry),
+		wakeup:      make(chan struct{}, 1),
+	}
+}
+
+// SetClock replaces the clock. It must be called before registering any entry.
+func (scheduler *Scheduler) SetClock(clock Clock) {
+	scheduler.clock = clock
 }
 
+// Start sleeps until the earliest activation time of the entries and sends the entries which are due to TriggeredEvent.
 func (scheduler *Scheduler) Start(ctx context.Context) {
 	c, cancelFunc := context.WithCancel(ctx)
 	scheduler.ctx = c
 	scheduler.cancel = cancelFunc
 
-	timer := time.NewTicker(interval)
-	defer timer.Stop()
-
+	fire := make(chan struct{}, 1)
 SchedulerLoop:
 	for {
+		timer := scheduler.clock.AfterFunc(scheduler.wait(), func() {
+			select {
+			case fire <- struct{}{}:
+			default:
+			}
+		})
+
 		select {
-		case <-timer.C:
-			t := time.Now()
-			for _, entry := range scheduler.entries {
-				if entry.CanExecute(t) {
-					scheduler.eventChan <- entry
-				}
+		case <-fire:
+			for _, entry := range scheduler.due(scheduler.clock.Now()) {
+				scheduler.eventChan <- entry
 			}
+		case <-scheduler.wakeup:
+			timer.Stop()
 		case <-scheduler.ctx.Done():
+			timer.Stop()
 			break SchedulerLoop
 		}
 	}
@@ -76,64 +190,470 @@ func (scheduler *Scheduler) Stop() {
 	scheduler.cancel()
 }
 
-func (scheduler *Scheduler) Every(interval time.Duration, channel string, f ScheduleFunc) error {
-	return scheduler.addEntry(interval, channel, f)
+func (scheduler *Scheduler) Every(interval time.Duration, channel string, f ScheduleFunc, opts ...ScheduleOption) error {
+	return scheduler.addEntry(interval, channel, f, opts...)
 }
 
-func (scheduler *Scheduler) At(every UnitTime, hour, minute int, channel string, f ScheduleFunc) error {
-	now := time.Now()
-	loc, err := time.LoadLocation("Local")
+func (scheduler *Scheduler) At(every UnitTime, hour, minute int, channel string, f ScheduleFunc, opts ...ScheduleOption) error {
+	var spec string
+	switch every {
+	case Hourly:
+		spec = fmt.Sprintf("%d * * * *", minute)
+	case Daily:
+		spec = fmt.Sprintf("%d %d * * *", minute, hour)
+	}
+
+	return scheduler.Cron(spec, time.Local, channel, f, opts...)
+}
+
+// Cron registers the entry which is activated by the cron expression in the time zone.
+func (scheduler *Scheduler) Cron(spec string, tz *time.Location, channel string, f ScheduleFunc, opts ...ScheduleOption) error {
+	schedule, err := ParseCron(spec, tz)
 	if err != nil {
 		return err
 	}
 
-	entry := &ScheduleEntry{Channel: channel, f: f}
-	switch every {
-	case Hourly:
-		next := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), minute, 0, 0, loc)
-		if now.After(next) {
-			next = next.Add(time.Hour)
+	scheduler.add(&ScheduleEntry{Description: spec, schedule: schedule, Channel: channel, f: f}, opts...)
+	return nil
+}
+
+// Once registers the entry which is activated only once at the time.
+// The entry is removed after the execution.
+func (scheduler *Scheduler) Once(at time.Time, channel string, f ScheduleFunc, opts ...ScheduleOption) string {
+	entry := &ScheduleEntry{Description: "once at " + at.Format("2006-01-02 15:04 MST"), schedule: &onceSchedule{at: at}, next: at, Channel: channel, f: f}
+	scheduler.add(entry, opts...)
+
+	return entry.Id
+}
+
+// Remind registers the reminder and stores it in Persistence.
+func (scheduler *Scheduler) Remind(reminder *Reminder) error {
+	if reminder.Id == "" {
+		reminder.Id = newEntryId()
+	}
+	buf, err := json.Marshal(reminder)
+	if err != nil {
+		return err
+	}
+	if err := scheduler.persistence.Set(reminderTableName, reminder.Id, buf); err != nil {
+		return err
+	}
+
+	scheduler.add(reminder.entry())
+	return nil
+}
+
+// ScheduleMessage registers the recurring message and stores it in Persistence.
+func (scheduler *Scheduler) ScheduleMessage(spec string, tz *time.Location, channel, text, createdBy string) (*ScheduledMessage, error) {
+	if tz == nil {
+		tz = time.Local
+	}
+	message := &ScheduledMessage{
+		Id:        newEntryId(),
+		Spec:      spec,
+		TimeZone:  tz.String(),
+		Channel:   channel,
+		Text:      text,
+		CreatedBy: createdBy,
+	}
+	entry, err := message.entry()
+	if err != nil {
+		return nil, err
+	}
+	if err := scheduler.save(message); err != nil {
+		return nil, err
+	}
+
+	scheduler.add(entry)
+	return message, nil
+}
+
+// Restore loads the recurring messages and the reminders from Persistence.
+// The activations which have passed while the bot was down are handled by the misfire policy at the next tick.
+// The entry which can't be restored, e.g. because of the unknown time zone, is logged and skipped.
+func (scheduler *Scheduler) Restore() error {
+	scheduler.restore(scheduleTableName, func(buf []byte) (*ScheduleEntry, error) {
+		message := &ScheduledMessage{}
+		if err := json.Unmarshal(buf, message); err != nil {
+			return nil, err
 		}
-		entry.interval = time.Hour
-		entry.next = next
-	case Daily:
-		next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, loc)
-		if now.After(next) {
-			next = next.Add(24 * time.Hour) // Add a day
+		return message.entry()
+	})
+	scheduler.restore(reminderTableName, func(buf []byte) (*ScheduleEntry, error) {
+		reminder := &Reminder{}
+		if err := json.Unmarshal(buf, reminder); err != nil {
+			return nil, err
 		}
-		entry.interval = 24 * time.Hour
-		entry.next = next
-	}
-	scheduler.entries = append(scheduler.entries, entry)
+		return reminder.entry(), nil
+	})
 
 	return nil
 }
 
+func (scheduler *Scheduler) restore(tableName string, f func([]byte) (*ScheduleEntry, error)) {
+	keys, err := scheduler.persistence.List(tableName)
+	if err != nil {
+		// The table doesn't exist until the first entry is stored.
+		return
+	}
+
+	for _, key := range keys {
+		buf, err := scheduler.persistence.Get(tableName, key)
+		if err != nil || buf == nil {
+			continue
+		}
+		entry, err := f(buf)
+		if err != nil {
+			log.Printf("scheduler: failed to restore %s/%s: %s", tableName, key, err)
+			continue
+		}
+
+		scheduler.add(entry)
+	}
+}
+
+// ScheduledMessages returns the copies of the entries of ScheduledMessage.
+// The entries which are registered in code and the reminders are not included.
+func (scheduler *Scheduler) ScheduledMessages() []ScheduleEntry {
+	scheduler.mutex.RLock()
+	defer scheduler.mutex.RUnlock()
+
+	entries := make([]ScheduleEntry, 0, len(scheduler.entries))
+	for _, e := range scheduler.entries {
+		if e.message != nil {
+			entries = append(entries, *e)
+		}
+	}
+
+	return entries
+}
+
+// isScheduledMessage reports whether the entry of the id is ScheduledMessage.
+func (scheduler *Scheduler) isScheduledMessage(id string) bool {
+	scheduler.mutex.RLock()
+	defer scheduler.mutex.RUnlock()
+
+	for _, e := range scheduler.entries {
+		if e.Id == id {
+			return e.message != nil
+		}
+	}
+
+	return false
+}
+
+// Entries returns the copies of the registered entries.
+func (scheduler *Scheduler) Entries() []ScheduleEntry {
+	scheduler.mutex.RLock()
+	defer scheduler.mutex.RUnlock()
+
+	entries := make([]ScheduleEntry, 0, len(scheduler.entries))
+	for _, e := range scheduler.entries {
+		entries = append(entries, *e)
+	}
+
+	return entries
+}
+
+func (scheduler *Scheduler) Remove(id string) error {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	for i, e := range scheduler.entries {
+		if e.Id != id {
+			continue
+		}
+		if err := scheduler.delete(e); err != nil {
+			return err
+		}
+		scheduler.entries = append(scheduler.entries[:i], scheduler.entries[i+1:]...)
+		return nil
+	}
+
+	return ErrEntryNotFound
+}
+
+func (scheduler *Scheduler) Pause(id string) error {
+	return scheduler.setPaused(id, true)
+}
+
+// Resume restarts the paused entry from the next activation time after now.
+func (scheduler *Scheduler) Resume(id string) error {
+	return scheduler.setPaused(id, false)
+}
+
 func (scheduler *Scheduler) TriggeredEvent() chan *ScheduleEntry {
 	return scheduler.eventChan
 }
 
-func (scheduler *Scheduler) addEntry(interval time.Duration, channel string, f ScheduleFunc) error {
-	if interval < time.Minute {
-		return ErrIntervalLessThanMinute
+func (scheduler *Scheduler) addEntry(interval time.Duration, channel string, f ScheduleFunc, opts ...ScheduleOption) error {
+	if interval <= 0 {
+		return ErrInvalidInterval
+	}
+
+	scheduler.add(&ScheduleEntry{Description: "every " + interval.String(), schedule: &intervalSchedule{interval: interval}, Channel: channel, f: f}, opts...)
+	return nil
+}
+
+func (scheduler *Scheduler) add(entry *ScheduleEntry, opts ...ScheduleOption) {
+	for _, opt := range opts {
+		opt(entry)
+	}
+	entry.running = make(chan struct{}, 1)
+	if entry.Id == "" {
+		entry.Id = newEntryId()
+	}
+	if entry.next.IsZero() {
+		entry.next = entry.schedule.Next(scheduler.clock.Now())
+	}
+
+	scheduler.mutex.Lock()
+	scheduler.entries = append(scheduler.entries, entry)
+	scheduler.mutex.Unlock()
+
+	scheduler.notify()
+}
+
+// notify makes Start recalculate the time to sleep.
+func (scheduler *Scheduler) notify() {
+	select {
+	case scheduler.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// wait returns the duration until the earliest activation time.
+// It doesn't sleep longer than the interval so that the change of the system time is followed.
+func (scheduler *Scheduler) wait() time.Duration {
+	scheduler.mutex.RLock()
+	defer scheduler.mutex.RUnlock()
+
+	now := scheduler.clock.Now()
+	d := interval
+	for _, entry := range scheduler.entries {
+		if entry.paused || entry.next.IsZero() {
+			continue
+		}
+		if w := entry.next.Sub(now); w < d {
+			d = w
+		}
+	}
+	if d < 0 {
+		return 0
+	}
+
+	return d
+}
+
+// due returns the entries which should be executed at t and advances them to the next activation time.
+// The next activation time is calculated from the previous one, so the schedule doesn't drift.
+// The entry which is activated more than once, or later than MisfireThreshold, is handled by its misfire policy,
+// and is returned as many times as it should be executed. The missed activations are counted up to MaxCatchUpRuns.
+// The entry which doesn't have the next activation time is removed.
+func (scheduler *Scheduler) due(t time.Time) []*ScheduleEntry {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	entries := make([]*ScheduleEntry, 0)
+	remaining := make([]*ScheduleEntry, 0, len(scheduler.entries))
+	for _, entry := range scheduler.entries {
+		if entry.paused || !entry.CanExecute(t) {
+			remaining = append(remaining, entry)
+			continue
+		}
+
+		late := t.Sub(entry.next) > MisfireThreshold
+		missed := 0
+		next := entry.schedule.Next(entry.next)
+		for !next.IsZero() && !next.After(t) {
+			missed++
+			if missed >= MaxCatchUpRuns {
+				// The rest of the missed activations are coalesced, e.g. the entry of every second after a long downtime.
+				next = entry.schedule.Next(t)
+				break
+			}
+			next = entry.schedule.Next(next)
+		}
+
+		runs := 1
+		if late || missed > 0 {
+			switch entry.misfire {
+			case MisfireSkip:
+				runs = 0
+			case MisfireRunAll:
+				runs += missed
+				if runs > MaxCatchUpRuns {
+					runs = MaxCatchUpRuns
+				}
+			}
+			log.Printf("scheduler: %d activation(s) of %s were missed, running %d", missed+1, entry.Id, runs)
+		}
+		for i := 0; i < runs; i++ {
+			entries = append(entries, entry)
+		}
+
+		entry.next = next
+		if entry.next.IsZero() {
+			if err := scheduler.delete(entry); err != nil {
+				log.Printf("failed to delete the entry %s: %s", entry.Id, err)
+			}
+			continue
+		}
+		if entry.message != nil {
+			entry.message.Next = entry.next
+			if err := scheduler.save(entry.message); err != nil {
+				log.Printf("failed to save the entry %s: %s", entry.Id, err)
+			}
+		}
+		remaining = append(remaining, entry)
+	}
+	scheduler.entries = remaining
+
+	return entries
+}
+
+// delete removes the entry from Persistence if the entry is stored.
+func (scheduler *Scheduler) delete(entry *ScheduleEntry) error {
+	switch {
+	case entry.message != nil:
+		return scheduler.persistence.Delete(scheduleTableName, entry.Id)
+	case entry.reminder != nil:
+		return scheduler.persistence.Delete(reminderTableName, entry.Id)
 	}
 
-	scheduler.entries = append(scheduler.entries, &ScheduleEntry{interval: interval, next: time.Now().Add(interval), Channel: channel, f: f})
 	return nil
 }
 
+func (scheduler *Scheduler) setPaused(id string, paused bool) error {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	for _, e := range scheduler.entries {
+		if e.Id != id {
+			continue
+		}
+		if e.message != nil {
+			e.message.Paused = paused
+			if err := scheduler.save(e.message); err != nil {
+				return err
+			}
+		}
+		if e.paused && !paused {
+			e.next = e.schedule.Next(scheduler.clock.Now())
+			defer scheduler.notify()
+		}
+		e.paused = paused
+		return nil
+	}
+
+	return ErrEntryNotFound
+}
+
+func (scheduler *Scheduler) save(message *ScheduledMessage) error {
+	buf, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	return scheduler.persistence.Set(scheduleTableName, message.Id, buf)
+}
+
+// CanExecute reports whether the entry is due at t.
+// The entry which doesn't have the next activation time is never due.
 func (entry *ScheduleEntry) CanExecute(t time.Time) bool {
+	if entry.next.IsZero() {
+		return false
+	}
 	if entry.next.Before(t) || entry.next.Equal(t) {
 		return true
 	}
 	return false
 }
 
-func (entry *ScheduleEntry) Execute(msg *Event) {
-	entry.next = time.Now().Add(entry.interval)
-	entry.f(msg)
+// Next returns the next activation time.
+func (entry *ScheduleEntry) Next() time.Time {
+	return entry.next
+}
+
+func (entry *ScheduleEntry) Paused() bool {
+	return entry.paused
+}
+
+// Execute runs the function of the entry through middlewares.
+// If the previous run is in progress, the run is skipped or waits for it according to the concurrency policy.
+func (entry *ScheduleEntry) Execute(msg *Event, middlewares ...Middleware) {
+	switch entry.concurrency {
+	case ConcurrencySkip:
+		select {
+		case entry.running <- struct{}{}:
+		default:
+			log.Printf("scheduler: skip %s since the previous run is in progress", entry.Id)
+			return
+		}
+		defer func() { <-entry.running }()
+	case ConcurrencyQueue:
+		entry.running <- struct{}{}
+		defer func() { <-entry.running }()
+	}
+
+	chain(HandlerFunc(entry.f), middlewares)(msg)
 }
 
 func (entry *ScheduleEntry) ToEvent() *Event {
 	return &Event{Type: ScheduledEvent, Channel: entry.Channel}
 }
+
+func (message *ScheduledMessage) entry() (*ScheduleEntry, error) {
+	tz, err := time.LoadLocation(message.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := ParseCron(message.Spec, tz)
+	if err != nil {
+		return nil, err
+	}
+	text := message.Text
+
+	return &ScheduleEntry{
+		Id:          message.Id,
+		Channel:     message.Channel,
+		Description: fmt.Sprintf("%s (%s) %q", message.Spec, message.TimeZone, message.Text),
+		schedule:    schedule,
+		next:        message.Next,
+		paused:      message.Paused,
+		message:     message,
+		f: func(event *Event) {
+			event.Say(text)
+		},
+	}, nil
+}
+
+func (s *intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+func (s *onceSchedule) Next(t time.Time) time.Time {
+	if s.at.After(t) {
+		return s.at
+	}
+
+	return time.Time{}
+}
+
+// lastEntryId is the number of the id which has been issued last by newEntryId.
+var lastEntryId int64
+
+// newEntryId returns the unique id which is based on the current time.
+// The ids which are issued in the same nanosecond are made unique by incrementing the last one.
+func newEntryId() string {
+	for {
+		last := atomic.LoadInt64(&lastEntryId)
+		id := time.Now().UnixNano()
+		if id <= last {
+			id = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&lastEntryId, last, id) {
+			return strconv.FormatInt(id, 36)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var testSchedulerStart = time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+// startTestScheduler starts the scheduler which uses TestClock. The scheduler is stopped by the returned function.
+func startTestScheduler() (*Scheduler, *TestClock, context.CancelFunc) {
+	clock := NewTestClock(testSchedulerStart)
+	scheduler := NewScheduler()
+	scheduler.SetClock(clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	go scheduler.Start(ctx)
+
+	return scheduler, clock, cancel
+}
+
+// triggered moves the clock to t and returns the number of the activations.
+func triggered(scheduler *Scheduler, clock *TestClock, t time.Time) int {
+	clock.BlockUntil(1)
+	clock.Set(t)
+
+	n := 0
+	for {
+		select {
+		case <-scheduler.TriggeredEvent():
+			n++
+		case <-time.After(testNoReplyWait):
+			return n
+		}
+	}
+}
+
+func TestScheduler_Misfire(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Policy MisfirePolicy
+		Runs   int
+	}{
+		{Name: "RunOnce", Policy: MisfireRunOnce, Runs: 1},
+		{Name: "Skip", Policy: MisfireSkip, Runs: 0},
+		{Name: "RunAll", Policy: MisfireRunAll, Runs: 10},
+	}
+
+	for _, c := range cases {
+		scheduler, clock, stop := startTestScheduler()
+		if err := scheduler.Every(time.Minute, "C1", func(_ *Event) {}, Misfire(c.Policy)); err != nil {
+			t.Fatal(err)
+		}
+
+		// The activation on time runs regardless of the policy.
+		if n := triggered(scheduler, clock, testSchedulerStart.Add(time.Minute)); n != 1 {
+			t.Errorf("%s: expected 1 activation on time: %d", c.Name, n)
+		}
+
+		// 10 activations from 9:02 to 9:11 are missed.
+		if n := triggered(scheduler, clock, testSchedulerStart.Add(11*time.Minute+30*time.Second)); n != c.Runs {
+			t.Errorf("%s: expected %d activations: %d", c.Name, c.Runs, n)
+		}
+		if next := scheduler.Entries()[0].Next(); !next.Equal(testSchedulerStart.Add(12 * time.Minute)) {
+			t.Errorf("%s: unexpected next activation: %s", c.Name, next)
+		}
+		stop()
+	}
+}
+
+func TestScheduler_MaxCatchUpRuns(t *testing.T) {
+	defer func(max int) { MaxCatchUpRuns = max }(MaxCatchUpRuns)
+	MaxCatchUpRuns = 5
+
+	scheduler, clock, stop := startTestScheduler()
+	defer stop()
+	if err := scheduler.Every(time.Minute, "C1", func(_ *Event) {}, Misfire(MisfireRunAll)); err != nil {
+		t.Fatal(err)
+	}
+
+	now := testSchedulerStart.Add(time.Hour + 30*time.Second)
+	if n := triggered(scheduler, clock, now); n != MaxCatchUpRuns {
+		t.Fatalf("expected %d activations: %d", MaxCatchUpRuns, n)
+	}
+	// The rest of the missed activations are coalesced.
+	if next := scheduler.Entries()[0].Next(); !next.Equal(now.Add(time.Minute)) {
+		t.Fatalf("unexpected next activation: %s", next)
+	}
+}
+
+func TestScheduleEntry_Concurrency(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Policy ConcurrencyPolicy
+		Runs   int
+	}{
+		{Name: "Allow", Policy: ConcurrencyAllow, Runs: 2},
+		{Name: "Skip", Policy: ConcurrencySkip, Runs: 1},
+		{Name: "Queue", Policy: ConcurrencyQueue, Runs: 2},
+	}
+
+	for _, c := range cases {
+		scheduler := NewScheduler()
+		started := make(chan struct{}, 2)
+		release := make(chan struct{})
+		err := scheduler.Every(time.Minute, "C1", func(_ *Event) {
+			started <- struct{}{}
+			<-release
+		}, Concurrency(c.Policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := scheduler.entries[0]
+
+		done := make(chan struct{}, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				entry.Execute(entry.ToEvent())
+				done <- struct{}{}
+			}()
+			if i == 0 {
+				<-started
+			}
+		}
+
+		runs := 1
+		select {
+		case <-started:
+			runs++
+		case <-time.After(testNoReplyWait):
+		}
+		close(release)
+		for i := 0; i < 2; i++ {
+			<-done
+		}
+		// The queued run starts after the previous one finishes.
+		select {
+		case <-started:
+			runs++
+		default:
+		}
+
+		if runs != c.Runs {
+			t.Errorf("%s: expected %d runs: %d", c.Name, c.Runs, runs)
+		}
+		if c.Policy == ConcurrencyQueue && len(started) != 0 {
+			t.Errorf("%s: the runs overlap", c.Name)
+		}
+	}
+}
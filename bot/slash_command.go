@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotSlashCommand = errors.New("the event is not a slash command")
+
+	// SlashCommandAckTimeout is the time which Connector waits for the acknowledgement of the slash command.
+	// Slack requires the response within 3 seconds.
+	SlashCommandAckTimeout = 2500 * time.Millisecond
+)
+
+// SlashCommand is the invocation of the slash command like "/deploy prod".
+// The arguments are in Event.Message.
+type SlashCommand struct {
+	// Name is the command which has the leading slash, e.g. "/deploy".
+	Name string
+	// ResponseURL is the URL for the delayed responses.
+	ResponseURL string
+
+	ack     chan string
+	settled bool
+	mutex   sync.Mutex
+}
+
+func NewSlashCommand(name, responseURL string) *SlashCommand {
+	return &SlashCommand{Name: name, ResponseURL: responseURL, ack: make(chan string, 1)}
+}
+
+// Ack answers the invocation with the text immediately.
+// It returns false if the invocation has been answered or SlashCommandAckTimeout has passed.
+func (c *SlashCommand) Ack(text string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.settled {
+		return false
+	}
+	c.settled = true
+	c.ack <- text
+
+	return true
+}
+
+// WaitAck is called by Connector and returns the text of the acknowledgement.
+// The text is empty if the handler finishes without Ack or the handler doesn't answer in timeout.
+func (c *SlashCommand) WaitAck(timeout time.Duration) string {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case text := <-c.ack:
+		return text
+	case <-timer.C:
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.settled {
+		return <-c.ack
+	}
+	c.settled = true
+
+	return ""
+}
+
+// slashCommandName returns the name with the leading slash.
+func slashCommandName(name string) string {
+	if strings.HasPrefix(name, "/") {
+		return name
+	}
+
+	return "/" + name
+}
@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TestClock is Clock for tests. The time doesn't move until Advance or Set is called.
+type TestClock struct {
+	now     time.Time
+	waiters []*testClockWaiter
+	changed *sync.Cond
+	mutex   sync.Mutex
+}
+
+type testClockWaiter struct {
+	clock *TestClock
+	at    time.Time
+	f     func()
+}
+
+func NewTestClock(now time.Time) *TestClock {
+	c := &TestClock{now: now, waiters: make([]*testClockWaiter, 0)}
+	c.changed = sync.NewCond(&c.mutex)
+	return c
+}
+
+func (c *TestClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+func (c *TestClock) AfterFunc(d time.Duration, f func()) Timer {
+	w := &testClockWaiter{clock: c, f: f}
+	c.add(w, d)
+	return w
+}
+
+// Advance moves the clock forward by d and fires the timers which expire in order.
+// Unlike SystemClock, the functions of the timers are called before Advance returns.
+func (c *TestClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to t and fires the timers which expire in order.
+func (c *TestClock) Set(t time.Time) {
+	c.mutex.Lock()
+	fired := make([]*testClockWaiter, 0)
+	rest := make([]*testClockWaiter, 0, len(c.waiters))
+	for _, w := range c.waiters {
+		if w.at.After(t) {
+			rest = append(rest, w)
+			continue
+		}
+		fired = append(fired, w)
+	}
+	c.waiters = rest
+	c.now = t
+	c.changed.Broadcast()
+	c.mutex.Unlock()
+
+	sort.SliceStable(fired, func(i, j int) bool { return fired[i].at.Before(fired[j].at) })
+	for _, w := range fired {
+		w.f()
+	}
+}
+
+// BlockUntil waits until n timers are pending.
+// It is used for making sure that a goroutine is waiting on the clock before calling Advance.
+func (c *TestClock) BlockUntil(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for len(c.waiters) < n {
+		c.changed.Wait()
+	}
+}
+
+func (c *TestClock) add(w *testClockWaiter, d time.Duration) {
+	// The timer which has already expired fires immediately, since the caller may hold a lock which f needs.
+	if d <= 0 {
+		go w.f()
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	w.at = c.now.Add(d)
+	c.waiters = append(c.waiters, w)
+	c.changed.Broadcast()
+}
+
+func (w *testClockWaiter) Stop() bool {
+	c := w.clock
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i, v := range c.waiters {
+		if v == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			c.changed.Broadcast()
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,17 +2,104 @@ package bot
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
 	"sync"
+	"time"
 )
 
+const (
+	testTsBase      = 1500000000
+	testNoReplyWait = 100 * time.Millisecond
+)
+
+var (
+	ErrTestTimeout = errors.New("test connector: timed out waiting for the message")
+)
+
+// TestingT is the subset of testing.TB which is used by TestConnector.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// TestMessage is the message which is sent by the bot through TestConnector.
+type TestMessage struct {
+	Channel  string
+	ThreadTs string
+	// Ts is the id of the message which is assigned by TestConnector.
+	Ts   string
+	Text string
+	// User is the receiver of the direct message.
+	User       string
+	Direct     bool
+	Confirm    bool
+	Attachment *TestAttachment
+	// Ephemeral is true if the message is shown only to User.
+	Ephemeral bool
+	// Response is true if the message is the answer of the slash command.
+	Response bool
+	// Rich is the message which is sent by SendRich. Text is the result of PlainText.
+	Rich *RichMessage
+	// Edited is true if the message is the new text of the message of Ts.
+	Edited bool
+	// Deleted is true if the message of Ts is deleted.
+	Deleted bool
+}
+
+type TestAttachment struct {
+	FileName string
+	Title    string
+	Content  []byte
+}
+
+// TestConnector is Connector for tests.
+// The test pushes events as users and awaits the messages which are sent by the bot.
 type TestConnector struct {
 	SendMessages []string
-	sync         sync.RWMutex
+	// EphemeralMessages is the texts which are sent by SendEphemeral. They are not included in SendMessages.
+	EphemeralMessages []string
+	// Timeout is the time to wait for the message in Await and TestTranscript.
+	Timeout time.Duration
+
+	eventChan chan *Event
+	idleChan  chan bool
+	messages  []TestMessage
+	read      int
+	seq       int
+	updated   chan struct{}
+	sync      sync.RWMutex
+}
+
+// TestTranscript asserts the conversation in the channel.
+//
+//	transcript := connector.Transcript(t, "C1")
+//	transcript.Expect("U1", "bot deploy prod").Reply("deploying prod", "done")
+type TestTranscript struct {
+	t         TestingT
+	connector *TestConnector
+	channel   string
+	thread    string
+}
+
+// TestExpectation is the message which is sent by the user in TestTranscript.
+type TestExpectation struct {
+	transcript *TestTranscript
+	event      *Event
 }
 
 func NewTestConnector() *TestConnector {
-	return &TestConnector{}
+	return &TestConnector{
+		Timeout:   time.Second,
+		eventChan: make(chan *Event, 100),
+		idleChan:  make(chan bool, 1),
+		messages:  make([]TestMessage, 0),
+		updated:   make(chan struct{}),
+	}
 }
 
 func (c *TestConnector) Connect() error {
@@ -24,7 +111,7 @@ func (c *TestConnector) Listen() error {
 }
 
 func (c *TestConnector) ReceivedEvent() chan *Event {
-	return make(chan *Event)
+	return c.eventChan
 }
 
 func (c *TestConnector) GetChannelInfo(channel string) (*ChannelInfo, error) {
@@ -35,21 +122,33 @@ func (c *TestConnector) GetChannelInfo(channel string) (*ChannelInfo, error) {
 	return ci, nil
 }
 
-func (c *TestConnector) Send(_ *Event, _username string, text string) error {
-	c.sync.Lock()
-	defer c.sync.Unlock()
-	c.SendMessages = append(c.SendMessages, text)
-	return nil
+func (c *TestConnector) Send(event *Event, _username string, text string) (*SentMessage, error) {
+	ts := c.record(TestMessage{Channel: event.Channel, Text: text})
+	return &SentMessage{Message: text, Channel: event.Channel, Timestamp: ts}, nil
 }
 
-func (c *TestConnector) SendWithConfirm(_ *Event, _username string, text string) (string, error) {
-	c.sync.Lock()
-	defer c.sync.Unlock()
-	c.SendMessages = append(c.SendMessages, text)
-	return "", nil
+func (c *TestConnector) SendWithConfirm(event *Event, _username string, text string) (string, error) {
+	return c.record(TestMessage{Channel: event.Channel, Text: text, Confirm: true}), nil
+}
+
+func (c *TestConnector) SendInThread(event *Event, _username, threadTs string, text string) (*SentMessage, error) {
+	ts := c.record(TestMessage{Channel: event.Channel, ThreadTs: threadTs, Text: text})
+	return &SentMessage{Message: text, Channel: event.Channel, Timestamp: ts}, nil
 }
 
-func (c *TestConnector) Attach(_ *Event, _fileName string, _file io.Reader, _title string) error {
+func (c *TestConnector) SendRich(event *Event, _username, threadTs string, message *RichMessage) (*SentMessage, error) {
+	text := message.PlainText()
+	ts := c.record(TestMessage{Channel: event.Channel, ThreadTs: threadTs, Text: text, Rich: message})
+	return &SentMessage{Message: text, Channel: event.Channel, Timestamp: ts}, nil
+}
+
+func (c *TestConnector) Attach(event *Event, fileName string, file io.Reader, title string) error {
+	buf, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	c.record(TestMessage{Channel: event.Channel, Text: title, Attachment: &TestAttachment{FileName: fileName, Title: title, Content: buf}})
 	return nil
 }
 
@@ -58,8 +157,40 @@ func (c *TestConnector) WithIndicate(channel string) context.CancelFunc {
 	return cancel
 }
 
-func (c *TestConnector) SendPrivate(event *Event, _userId string, text string) error {
-	return c.Send(event, "", text)
+func (c *TestConnector) SendPrivate(_ *Event, userId string, text string) (*SentMessage, error) {
+	ts := c.record(TestMessage{Channel: userId, User: userId, Text: text, Direct: true})
+	return &SentMessage{Message: text, Channel: userId, Timestamp: ts}, nil
+}
+
+// UpdateMessage records the new text as the message which has the same Ts with Edited.
+func (c *TestConnector) UpdateMessage(message *SentMessage, text string) (*SentMessage, error) {
+	c.record(TestMessage{Channel: message.Channel, Ts: message.Timestamp, Text: text, Edited: true})
+	return &SentMessage{Message: text, Channel: message.Channel, Timestamp: message.Timestamp}, nil
+}
+
+// UpdateRich records the new message as the message which has the same Ts with Edited.
+func (c *TestConnector) UpdateRich(message *SentMessage, rich *RichMessage) (*SentMessage, error) {
+	text := rich.PlainText()
+	c.record(TestMessage{Channel: message.Channel, Ts: message.Timestamp, Text: text, Rich: rich, Edited: true})
+	return &SentMessage{Message: text, Channel: message.Channel, Timestamp: message.Timestamp}, nil
+}
+
+// DeleteMessage records the message which has the same Ts with Deleted.
+func (c *TestConnector) DeleteMessage(message *SentMessage) error {
+	c.record(TestMessage{Channel: message.Channel, Ts: message.Timestamp, Deleted: true})
+	return nil
+}
+
+// SendEphemeral records the message which is shown only to the user.
+func (c *TestConnector) SendEphemeral(event *Event, userId, text string) error {
+	c.record(TestMessage{Channel: event.Channel, ThreadTs: event.ThreadTs, User: userId, Text: text, Ephemeral: true})
+	return nil
+}
+
+// RespondSlashCommand records the response to the user of the event.
+func (c *TestConnector) RespondSlashCommand(event *Event, text string, inChannel bool) error {
+	c.record(TestMessage{Channel: event.Channel, User: event.User.Id, Text: text, Ephemeral: !inChannel, Response: true})
+	return nil
 }
 
 func (c *TestConnector) Async() bool {
@@ -67,9 +198,264 @@ func (c *TestConnector) Async() bool {
 }
 
 func (c *TestConnector) Idle() chan bool {
-	return make(chan bool)
+	return c.idleChan
 }
 
 func (c *TestConnector) GetPermalink(_event *Event) string {
 	return "test-connector-permalink"
 }
+
+// Push sends the event to the bot. If the event doesn't have Ts, new one is assigned.
+func (c *TestConnector) Push(event *Event) *Event {
+	if event.Ts == "" {
+		c.sync.Lock()
+		event.Ts = c.nextTs()
+		c.sync.Unlock()
+	}
+
+	c.eventChan <- event
+	return event
+}
+
+// Message sends the message from the user in the channel.
+func (c *TestConnector) Message(channel, user, text string) *Event {
+	return c.Push(&Event{Type: MessageEvent, Channel: channel, User: User{Id: user}, Message: text})
+}
+
+// ThreadMessage sends the message from the user in the thread.
+func (c *TestConnector) ThreadMessage(channel, thread, user, text string) *Event {
+	return c.Push(&Event{Type: MessageEvent, Channel: channel, ThreadTs: thread, User: User{Id: user}, Message: text})
+}
+
+// Reaction adds the reaction to the message of ts by the user.
+func (c *TestConnector) Reaction(channel, ts, user, reaction string) *Event {
+	return c.Push(&Event{Type: ReactionAddedEvent, Channel: channel, Ts: ts, User: User{Id: user}, Reaction: reaction})
+}
+
+// EditMessage edits the message which has been pushed by the user.
+func (c *TestConnector) EditMessage(original *Event, text string) *Event {
+	return c.Push(&Event{
+		Type:            MessageChangedEvent,
+		Channel:         original.Channel,
+		ThreadTs:        original.ThreadTs,
+		Ts:              original.Ts,
+		User:            original.User,
+		Message:         text,
+		PreviousMessage: original.Message,
+	})
+}
+
+// RemoveMessage deletes the message which has been pushed by the user.
+func (c *TestConnector) RemoveMessage(original *Event) *Event {
+	return c.Push(&Event{
+		Type:            MessageDeletedEvent,
+		Channel:         original.Channel,
+		ThreadTs:        original.ThreadTs,
+		Ts:              original.Ts,
+		User:            original.User,
+		PreviousMessage: original.Message,
+	})
+}
+
+// RemoveReaction removes the reaction from the message of ts by the user.
+func (c *TestConnector) RemoveReaction(channel, ts, user, reaction string) *Event {
+	return c.Push(&Event{Type: ReactionRemovedEvent, Channel: channel, Ts: ts, User: User{Id: user}, Reaction: reaction})
+}
+
+// Join makes the user join the channel.
+func (c *TestConnector) Join(channel, user string) *Event {
+	return c.Push(&Event{Type: MemberJoinedChannelEvent, Channel: channel, User: User{Id: user}})
+}
+
+// Action operates the element of the message of ts by the user.
+func (c *TestConnector) Action(channel, ts, user, actionId, value string) *Event {
+	return c.Push(&Event{Type: InteractionEvent, Channel: channel, Ts: ts, User: User{Id: user}, Action: &Action{Id: actionId, Value: value}})
+}
+
+// SlashCommand invokes the slash command by the user in the channel.
+// The acknowledgement by the handler is recorded as the ephemeral response.
+func (c *TestConnector) SlashCommand(channel, user, command, text string) *Event {
+	slashCommand := NewSlashCommand(slashCommandName(command), "")
+	event := c.Push(&Event{Type: SlashCommandEvent, Channel: channel, User: User{Id: user}, Message: text, SlashCommand: slashCommand})
+	if ack := slashCommand.WaitAck(SlashCommandAckTimeout); ack != "" {
+		c.record(TestMessage{Channel: channel, User: user, Text: ack, Ephemeral: true, Response: true})
+	}
+
+	return event
+}
+
+// Messages returns all messages which have been sent by the bot.
+func (c *TestConnector) Messages() []TestMessage {
+	c.sync.RLock()
+	defer c.sync.RUnlock()
+
+	messages := make([]TestMessage, len(c.messages))
+	copy(messages, c.messages)
+	return messages
+}
+
+// Await returns the oldest message which hasn't been returned by Await yet.
+// If there is no such message, it waits for the message until timeout.
+func (c *TestConnector) Await(timeout time.Duration) (*TestMessage, error) {
+	deadline := time.After(timeout)
+	for {
+		c.sync.Lock()
+		if c.read < len(c.messages) {
+			m := c.messages[c.read]
+			c.read++
+			c.sync.Unlock()
+			return &m, nil
+		}
+		updated := c.updated
+		c.sync.Unlock()
+
+		select {
+		case <-updated:
+		case <-deadline:
+			return nil, ErrTestTimeout
+		}
+	}
+}
+
+// Transcript returns TestTranscript for the conversation in the channel.
+func (c *TestConnector) Transcript(t TestingT, channel string) *TestTranscript {
+	return &TestTranscript{t: t, connector: c, channel: channel}
+}
+
+func (c *TestConnector) record(m TestMessage) string {
+	c.sync.Lock()
+	defer c.sync.Unlock()
+
+	if m.Ts == "" {
+		m.Ts = c.nextTs()
+	}
+	switch {
+	case m.Ephemeral:
+		c.EphemeralMessages = append(c.EphemeralMessages, m.Text)
+	case !m.Edited && !m.Deleted:
+		c.SendMessages = append(c.SendMessages, m.Text)
+	}
+	c.messages = append(c.messages, m)
+	close(c.updated)
+	c.updated = make(chan struct{})
+
+	return m.Ts
+}
+
+// nextTs returns the unique timestamp. The caller must hold the lock.
+func (c *TestConnector) nextTs() string {
+	c.seq++
+	return fmt.Sprintf("%d.%06d", testTsBase, c.seq)
+}
+
+// InThread makes the following messages to be sent in the thread.
+func (tr *TestTranscript) InThread(thread string) *TestTranscript {
+	return &TestTranscript{t: tr.t, connector: tr.connector, channel: tr.channel, thread: thread}
+}
+
+// Expect sends the message as the user.
+// The test fails if the bot has sent any message which isn't asserted yet.
+func (tr *TestTranscript) Expect(user, text string) *TestExpectation {
+	tr.t.Helper()
+
+	tr.connector.sync.RLock()
+	unread := tr.connector.messages[tr.connector.read:]
+	tr.connector.sync.RUnlock()
+	for _, m := range unread {
+		tr.t.Errorf("unexpected message before %q: %s", text, m)
+	}
+	tr.connector.sync.Lock()
+	tr.connector.read += len(unread)
+	tr.connector.sync.Unlock()
+
+	event := tr.connector.ThreadMessage(tr.channel, tr.thread, user, text)
+	return &TestExpectation{transcript: tr, event: event}
+}
+
+// Reply asserts that the bot sends the messages in order.
+func (e *TestExpectation) Reply(texts ...string) *TestExpectation {
+	e.transcript.t.Helper()
+
+	for _, text := range texts {
+		m, ok := e.await(text)
+		if !ok {
+			return e
+		}
+		if m.Text != text {
+			e.transcript.t.Errorf("reply to %q: expected %q but got %q", e.event.Message, text, m.Text)
+		}
+	}
+
+	return e
+}
+
+// ReplyMatch asserts that the bot sends the message which matches the regular expression.
+func (e *TestExpectation) ReplyMatch(pattern string) *TestExpectation {
+	e.transcript.t.Helper()
+
+	m, ok := e.await(pattern)
+	if !ok {
+		return e
+	}
+	if !regexp.MustCompile(pattern).MatchString(m.Text) {
+		e.transcript.t.Errorf("reply to %q: expected to match %q but got %q", e.event.Message, pattern, m.Text)
+	}
+
+	return e
+}
+
+// NoReply asserts that the bot doesn't send any message for a while.
+func (e *TestExpectation) NoReply() *TestExpectation {
+	e.transcript.t.Helper()
+
+	if m, err := e.transcript.connector.Await(testNoReplyWait); err == nil {
+		e.transcript.t.Errorf("reply to %q: expected no reply but got %q", e.event.Message, m.Text)
+	}
+
+	return e
+}
+
+func (e *TestExpectation) await(expected string) (*TestMessage, bool) {
+	e.transcript.t.Helper()
+
+	m, err := e.transcript.connector.Await(e.transcript.connector.Timeout)
+	if err != nil {
+		e.transcript.t.Errorf("reply to %q: expected %q but %s", e.event.Message, expected, err)
+		return nil, false
+	}
+	if m.Channel != e.transcript.channel && !m.Direct {
+		e.transcript.t.Errorf("reply to %q: expected in %s but sent to %s: %q", e.event.Message, e.transcript.channel, m.Channel, m.Text)
+	}
+	if e.transcript.thread != "" && m.ThreadTs != e.transcript.thread && !m.Direct {
+		e.transcript.t.Errorf("reply to %q: expected in thread %s but sent to %q: %q", e.event.Message, e.transcript.thread, m.ThreadTs, m.Text)
+	}
+
+	return m, true
+}
+
+func (m TestMessage) String() string {
+	var b strings.Builder
+	if m.Direct {
+		fmt.Fprintf(&b, "@%s", m.User)
+	} else {
+		fmt.Fprintf(&b, "#%s", m.Channel)
+	}
+	if m.ThreadTs != "" {
+		fmt.Fprintf(&b, "/%s", m.ThreadTs)
+	}
+	if m.Ephemeral {
+		fmt.Fprintf(&b, " (only @%s)", m.User)
+	}
+	switch {
+	case m.Edited:
+		fmt.Fprintf(&b, " (edited %s)", m.Ts)
+	case m.Deleted:
+		fmt.Fprintf(&b, " (deleted %s)", m.Ts)
+	}
+	fmt.Fprintf(&b, ": %s", m.Text)
+	if m.Attachment != nil {
+		fmt.Fprintf(&b, " [%s]", m.Attachment.FileName)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,172 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/f110/montegrappa/persistence"
+)
+
+// recordingT is TestingT which records the failures instead of failing the test.
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+// startTestBot starts the bot with TestConnector. The bot is stopped by the returned function.
+func startTestBot() (*Bot, *TestConnector, context.CancelFunc) {
+	b, connector := newTestBot()
+	return b, connector, runTestBot(b)
+}
+
+// newTestBot returns the bot with TestConnector which isn't started yet.
+// It is used when Persistence is prepared before the bot starts.
+func newTestBot() (*Bot, *TestConnector) {
+	connector := NewTestConnector()
+	return NewBot(connector, persistence.NewMemoryDB(), "bot", nil, nil), connector
+}
+
+// runTestBot starts the bot. The bot is stopped by the returned function.
+func runTestBot(b *Bot) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	go b.Start(ctx)
+
+	return cancel
+}
+
+func TestTestConnector_Transcript(t *testing.T) {
+	b, connector, stop := startTestBot()
+	defer stop()
+	b.Command("ping", "", func(event *Event) {
+		event.Say("pong")
+	})
+	b.Command("deploy", "", func(event *Event) {
+		event.Say("deploying")
+		event.Say("done")
+	})
+
+	transcript := connector.Transcript(t, "C1")
+	transcript.Expect("U1", "bot ping").Reply("pong")
+	transcript.Expect("U1", "bot deploy").Reply("deploying", "done")
+	transcript.Expect("U1", "hello").NoReply()
+	transcript.Expect("U1", "bot ping").ReplyMatch("^po")
+}
+
+func TestTestConnector_TranscriptFailure(t *testing.T) {
+	b, connector, stop := startTestBot()
+	defer stop()
+	connector.Timeout = 100 * time.Millisecond
+	b.Command("ping", "", func(event *Event) {
+		event.Say("pong")
+	})
+
+	rt := &recordingT{}
+	transcript := connector.Transcript(rt, "C1")
+	transcript.Expect("U1", "bot ping").Reply("ping")
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], `expected "ping" but got "pong"`) {
+		t.Fatalf("the wrong reply is not reported: %v", rt.errors)
+	}
+
+	rt.errors = nil
+	transcript.Expect("U1", "hello").Reply("hi")
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], ErrTestTimeout.Error()) {
+		t.Fatalf("the missing reply is not reported: %v", rt.errors)
+	}
+
+	rt.errors = nil
+	transcript.Expect("U1", "bot ping").NoReply()
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], `expected no reply but got "pong"`) {
+		t.Fatalf("the unexpected reply is not reported: %v", rt.errors)
+	}
+
+	// The reply which isn't asserted is reported by the next Expect.
+	rt.errors = nil
+	connector.Message("C1", "U1", "bot ping")
+	time.Sleep(testNoReplyWait)
+	transcript.Expect("U1", "hello").NoReply()
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], `unexpected message before "hello"`) {
+		t.Fatalf("the unread reply is not reported: %v", rt.errors)
+	}
+}
+
+func TestTestConnector_Await(t *testing.T) {
+	connector := NewTestConnector()
+
+	start := time.Now()
+	if _, err := connector.Await(50 * time.Millisecond); err != ErrTestTimeout {
+		t.Fatalf("expected ErrTestTimeout: %v", err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatal("Await returned before the timeout")
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		connector.Send(&Event{Channel: "C1"}, "bot", "first")
+		connector.Send(&Event{Channel: "C1"}, "bot", "second")
+	}()
+	for _, text := range []string{"first", "second"} {
+		m, err := connector.Await(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Text != text || m.Channel != "C1" || m.Ts == "" {
+			t.Fatalf("unexpected message: %+v", m)
+		}
+	}
+	if len(connector.Messages()) != 2 {
+		t.Fatalf("expected 2 messages: %d", len(connector.Messages()))
+	}
+}
+
+func TestTestConnector_Direct(t *testing.T) {
+	b, connector, stop := startTestBot()
+	defer stop()
+	b.Command("secret", "", func(event *Event) {
+		event.Direct("the password is hunter2")
+	})
+
+	connector.Transcript(t, "C1").Expect("U1", "bot secret").Reply("the password is hunter2")
+
+	m := connector.Messages()[0]
+	if !m.Direct || m.User != "U1" {
+		t.Fatalf("the message is not sent to the user directly: %+v", m)
+	}
+	if m.String() != "@U1: the password is hunter2" {
+		t.Fatalf("unexpected string: %s", m.String())
+	}
+}
+
+func TestTestConnector_Thread(t *testing.T) {
+	b, connector, stop := startTestBot()
+	defer stop()
+	b.Command("status", "", func(event *Event) {
+		event.SayInThread("all green")
+	})
+	b.Command("broadcast", "", func(event *Event) {
+		event.Say("hello everyone")
+	})
+
+	thread := connector.Message("C1", "U1", "start").Ts
+	transcript := connector.Transcript(t, "C1").InThread(thread)
+	transcript.Expect("U1", "bot status").Reply("all green")
+
+	m := connector.Messages()[0]
+	if m.ThreadTs != thread {
+		t.Fatalf("the message is not sent to the thread: %+v", m)
+	}
+
+	rt := &recordingT{}
+	connector.Transcript(rt, "C1").InThread(thread).Expect("U1", "bot broadcast").Reply("hello everyone")
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "expected in thread "+thread) {
+		t.Fatalf("the message out of the thread is not reported: %v", rt.errors)
+	}
+}
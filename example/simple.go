@@ -23,17 +23,18 @@ func main() {
 	robot.Command("ping", "ping pong", func(msg *bot.Event) {
 		msg.Sayf("pong %l", msg.User)
 	})
+	robot.RegisterDialog(&bot.Dialog{
+		Name:    "test",
+		Timeout: 1 * time.Minute,
+		Steps: []bot.DialogStep{
+			{Name: "res", Prompt: "どうしましたか？"},
+		},
+		OnComplete: func(msg *bot.Event, result *bot.DialogResult) {
+			msg.Say(result.String("res"))
+		},
+	})
 	robot.Command("test", "test", func(msg *bot.Event) {
-		cancelFunc, resChan := msg.SayRequireResponse("どうしましたか？")
-		go func() {
-			t := time.Tick(1 * time.Minute)
-			select {
-			case <-t:
-				cancelFunc()
-			}
-		}()
-		res := <-resChan
-		msg.Say(res)
+		msg.StartDialog("test")
 	})
 	robot.Command("channels", "channels", func(msg *bot.Event) {
 		channels, err := msg.Bot.Connector.(*slack.Connector).GetJoinedChannelList()
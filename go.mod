@@ -1,10 +1,12 @@
 module github.com/f110/montegrappa
 
+go 1.13
+
 require (
 	github.com/boltdb/bolt v1.3.0
 	github.com/golang/protobuf v0.0.0-20161117033126-8ee79997227b // indirect
 	github.com/gorilla/websocket v1.4.0 // indirect
-	github.com/nlopes/slack v0.5.0 // indirect
+	github.com/nlopes/slack v0.5.0
 	github.com/pkg/errors v0.8.1 // indirect
 	golang.org/x/net v0.0.0-20170110034938-60c41d1de8da
 	golang.org/x/oauth2 v0.0.0-20161219192954-314dd2c0bf3e
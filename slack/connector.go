@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"log"
 	"strconv"
@@ -13,7 +12,6 @@ import (
 	"time"
 
 	"github.com/f110/montegrappa/bot"
-	"github.com/nlopes/slack"
 	"golang.org/x/net/websocket"
 )
 
@@ -30,14 +28,12 @@ type Connector struct {
 	eventChan      chan *bot.Event
 	idle           chan bool
 
-	token      string
-	teamId     string
-	domain     string
+	webClient
+
 	bufChan    chan []byte
 	errorChan  chan error
 	startTime  int
 	connection *websocket.Conn
-	client     *slack.Client
 }
 
 type Ping struct {
@@ -52,54 +48,15 @@ type Typing struct {
 	Channel string `json:"channel"`
 }
 
-type Event struct {
-	Type string
-	Ts   string
-	Raw  []byte
-}
-
-type Message struct {
-	Type    string
-	SubType string `json:"subtype"`
-	Ts      string
-	Channel string
-	User    string
-	Text    string
-	ts      string
-}
-
-type UserTyping struct {
-	Type    string
-	Channel string
-	User    string
-}
-
-type ReactionAdded struct {
-	Type     string `json:"type"`
-	User     string `json:"user"`
-	Reaction string `json:"reaction"`
-	ItemUser string `json:"item_user"`
-	Item     struct {
-		Type        string `json:"type"`
-		Channel     string `json:"channel"`
-		Ts          string `json:"ts"`
-		File        string `json:"file"`
-		FileComment string `json:"file_comment"`
-	} `json:"item"`
-	EventTs string `json:"event_ts"`
-}
-
 func NewConnector(teamId, token string) *Connector {
 	startTime := int(time.Now().Unix())
 
 	return &Connector{
-		token:     token,
-		teamId:    teamId,
+		webClient: newWebClient(teamId, token),
 		startTime: startTime,
 		eventChan: make(chan *bot.Event),
 		errorChan: make(chan error),
 		mutex:     &sync.Mutex{},
-		client:    slack.New(token),
 	}
 }
 
@@ -125,10 +82,6 @@ func (*Connector) Async() bool {
 	return true
 }
 
-func (connector *Connector) Client() *slack.Client {
-	return connector.client
-}
-
 func (connector *Connector) Listen() error {
 	for {
 		select {
@@ -146,51 +99,9 @@ func (connector *Connector) Listen() error {
 				}
 			}
 
-			botEvent := new(bot.Event)
-			switch event.Type {
-			case "message":
-				var messageEvent Message
-				if err := json.Unmarshal(buf, &messageEvent); err != nil {
-					continue
-				}
-				if messageEvent.User == "" {
-					continue
-				}
-
-				botEvent.Type = bot.MessageEvent
-				botEvent.Message = messageEvent.Text
-				botEvent.Channel = messageEvent.Channel
-				botEvent.User.Id = messageEvent.User
-				botEvent.Ts = messageEvent.Ts
-			case "user_typing":
-				var userTypingEvent UserTyping
-				if err := json.Unmarshal(buf, &userTypingEvent); err != nil {
-					continue
-				}
-				if userTypingEvent.User == "" {
-					continue
-				}
-
-				botEvent.Type = bot.UserTypingEvent
-				botEvent.Channel = userTypingEvent.Channel
-				botEvent.User.Id = userTypingEvent.User
-			case "pong":
+			botEvent := connector.toBotEvent(buf)
+			if botEvent == nil {
 				continue
-			case "reaction_added":
-				botEvent.Type = bot.ReactionAddedEvent
-				reactionAdded := new(ReactionAdded)
-				if err := json.Unmarshal(buf, reactionAdded); err != nil {
-					continue
-				}
-				if reactionAdded.Item.Type != "message" {
-					continue
-				}
-				botEvent.Channel = reactionAdded.Item.Channel
-				botEvent.Ts = reactionAdded.Item.Ts
-				botEvent.User.Id = reactionAdded.User
-				botEvent.Reaction = reactionAdded.Reaction
-			default:
-				botEvent.Type = bot.UnknownEvent
 			}
 
 			connector.eventChan <- botEvent
@@ -208,46 +119,6 @@ func (connector *Connector) Idle() chan bool {
 	return connector.idle
 }
 
-func (connector *Connector) Send(event *bot.Event, username string, text string) error {
-	_, _, err := connector.client.PostMessage(event.Channel, slack.MsgOptionUsername(username), slack.MsgOptionText(text, false))
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (connector *Connector) SendWithConfirm(event *bot.Event, username, text string) (string, error) {
-	_, ts, err := connector.client.PostMessage(event.Channel, slack.MsgOptionUsername(username), slack.MsgOptionText(text, false))
-	if err != nil {
-		return "", err
-	}
-
-	return ts, nil
-}
-
-func (connector *Connector) SendPrivate(event *bot.Event, userId, text string) error {
-	_, _, channelId, err := connector.client.OpenIMChannel(userId)
-	if err != nil {
-		return err
-	}
-
-	_, _, err = connector.client.PostMessage(channelId, slack.MsgOptionText(text, false))
-	return err
-}
-
-func (connector *Connector) Attach(event *bot.Event, fileName string, file io.Reader, title string) error {
-	_, err := connector.client.UploadFile(slack.FileUploadParameters{
-		Filename: fileName,
-		Channels: []string{event.Channel},
-		Reader:   file,
-		Title:    title,
-		Filetype: "auto",
-	})
-
-	return err
-}
-
 func (connector *Connector) WithIndicate(channel string) context.CancelFunc {
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -267,34 +138,6 @@ func (connector *Connector) WithIndicate(channel string) context.CancelFunc {
 	return cancel
 }
 
-func (connector *Connector) GetPermalink(event *bot.Event) string {
-	return fmt.Sprintf("https://%s.slack.com/archives/%s/p%s", connector.teamDomain(), event.Channel, strings.Replace(event.Ts, ".", "", -1))
-}
-
-func (connector *Connector) teamDomain() string {
-	if connector.domain == "" {
-		info, err := connector.client.GetTeamInfo()
-		if err != nil {
-			return ""
-		}
-		connector.domain = info.Domain
-	}
-
-	return connector.domain
-}
-
-func (connector *Connector) GetChannelInfo(channelId string) (*bot.ChannelInfo, error) {
-	channel, err := connector.client.GetChannelInfo(channelId)
-	if err != nil {
-		return nil, err
-	}
-
-	var res bot.ChannelInfo
-	res.Name = channel.Name
-	res.Id = channel.ID
-	return &res, nil
-}
-
 func (connector *Connector) startReading() {
 	log.Print("start reading")
 	var msg []byte
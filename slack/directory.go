@@ -0,0 +1,122 @@
+package slack
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/f110/montegrappa/bot"
+)
+
+// CacheTTL is the time during which the resolved users and channels are reused.
+var CacheTTL = 10 * time.Minute
+
+// UserChange is the event which is sent when the profile of the user is changed.
+type UserChange struct {
+	Type string `json:"type"`
+	User struct {
+		Id string `json:"id"`
+	} `json:"user"`
+}
+
+// ChannelRename is the event which is sent when the channel is renamed.
+type ChannelRename struct {
+	Type    string `json:"type"`
+	Channel struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channel"`
+}
+
+// directory caches the users and the channels which are resolved by the Web API.
+type directory struct {
+	users    map[string]cachedUser
+	channels map[string]cachedChannel
+	mutex    sync.Mutex
+}
+
+type cachedUser struct {
+	user    bot.User
+	expires time.Time
+}
+
+type cachedChannel struct {
+	channel bot.ChannelInfo
+	expires time.Time
+}
+
+func newDirectory() *directory {
+	return &directory{users: make(map[string]cachedUser), channels: make(map[string]cachedChannel)}
+}
+
+func (d *directory) user(id string) (bot.User, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	c, ok := d.users[id]
+	if !ok || time.Now().After(c.expires) {
+		return bot.User{}, false
+	}
+
+	return c.user, true
+}
+
+func (d *directory) setUser(user bot.User) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.users[user.Id] = cachedUser{user: user, expires: time.Now().Add(CacheTTL)}
+}
+
+func (d *directory) channel(id string) (bot.ChannelInfo, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	c, ok := d.channels[id]
+	if !ok || time.Now().After(c.expires) {
+		return bot.ChannelInfo{}, false
+	}
+
+	return c.channel, true
+}
+
+func (d *directory) setChannel(channel bot.ChannelInfo) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.channels[channel.Id] = cachedChannel{channel: channel, expires: time.Now().Add(CacheTTL)}
+}
+
+// invalidate removes the user or the channel which is changed by the event.
+// The channel which is created by the event is cached instead.
+func (d *directory) invalidate(buf []byte) {
+	var event Event
+	if err := json.Unmarshal(buf, &event); err != nil {
+		return
+	}
+
+	switch event.Type {
+	case "user_change":
+		var change UserChange
+		if err := json.Unmarshal(buf, &change); err != nil {
+			return
+		}
+		d.mutex.Lock()
+		delete(d.users, change.User.Id)
+		d.mutex.Unlock()
+	case "channel_rename":
+		var rename ChannelRename
+		if err := json.Unmarshal(buf, &rename); err != nil {
+			return
+		}
+		d.mutex.Lock()
+		delete(d.channels, rename.Channel.Id)
+		d.mutex.Unlock()
+	case "channel_created":
+		var created ChannelCreated
+		if err := json.Unmarshal(buf, &created); err != nil {
+			return
+		}
+		d.setChannel(bot.ChannelInfo{Id: created.Channel.Id, Name: created.Channel.Name})
+	}
+}
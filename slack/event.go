@@ -0,0 +1,278 @@
+package slack
+
+import (
+	"encoding/json"
+
+	"github.com/f110/montegrappa/bot"
+)
+
+type Event struct {
+	Type string
+	Ts   string
+	Raw  []byte
+}
+
+type Message struct {
+	Type     string
+	SubType  string `json:"subtype"`
+	Ts       string
+	ThreadTs string `json:"thread_ts"`
+	Channel  string
+	User     string
+	Text     string
+	ts       string
+	// Message and PreviousMessage are set in message_changed and message_deleted.
+	Message         *Message `json:"message"`
+	PreviousMessage *Message `json:"previous_message"`
+	DeletedTs       string   `json:"deleted_ts"`
+}
+
+type UserTyping struct {
+	Type    string
+	Channel string
+	User    string
+}
+
+type ReactionAdded struct {
+	Type     string `json:"type"`
+	User     string `json:"user"`
+	Reaction string `json:"reaction"`
+	ItemUser string `json:"item_user"`
+	Item     struct {
+		Type        string `json:"type"`
+		Channel     string `json:"channel"`
+		Ts          string `json:"ts"`
+		File        string `json:"file"`
+		FileComment string `json:"file_comment"`
+	} `json:"item"`
+	EventTs string `json:"event_ts"`
+}
+
+// ReactionRemoved has the same fields as ReactionAdded.
+type ReactionRemoved = ReactionAdded
+
+type MemberJoinedChannel struct {
+	Type    string `json:"type"`
+	User    string `json:"user"`
+	Channel string `json:"channel"`
+	Inviter string `json:"inviter"`
+}
+
+type ChannelCreated struct {
+	Type    string `json:"type"`
+	Channel struct {
+		Id      string `json:"id"`
+		Name    string `json:"name"`
+		Creator string `json:"creator"`
+	} `json:"channel"`
+}
+
+// Interaction is the payload of interactive components.
+// Both of block_actions and legacy interactive_message are supported.
+type Interaction struct {
+	Type string `json:"type"`
+	User struct {
+		Id string `json:"id"`
+	} `json:"user"`
+	Channel struct {
+		Id string `json:"id"`
+	} `json:"channel"`
+	Container struct {
+		MessageTs string `json:"message_ts"`
+	} `json:"container"`
+	MessageTs  string `json:"message_ts"`
+	CallbackId string `json:"callback_id"`
+	Actions    []struct {
+		ActionId       string `json:"action_id"`
+		Name           string `json:"name"`
+		BlockId        string `json:"block_id"`
+		Value          string `json:"value"`
+		SelectedOption struct {
+			Value string `json:"value"`
+		} `json:"selected_option"`
+		// SelectedOptions is the menu of legacy interactive_message.
+		SelectedOptions []struct {
+			Value string `json:"value"`
+		} `json:"selected_options"`
+	} `json:"actions"`
+}
+
+// toBotEvent converts a raw event payload into bot.Event.
+// The payload format is shared by the RTM API and the Events API.
+// It returns nil if the event should be ignored.
+func toBotEvent(buf []byte) *bot.Event {
+	var event Event
+	if err := json.Unmarshal(buf, &event); err != nil {
+		return nil
+	}
+
+	botEvent := new(bot.Event)
+	switch event.Type {
+	case "message":
+		var messageEvent Message
+		if err := json.Unmarshal(buf, &messageEvent); err != nil {
+			return nil
+		}
+		switch messageEvent.SubType {
+		case "message_changed":
+			return toMessageChangedEvent(&messageEvent)
+		case "message_deleted":
+			return toMessageDeletedEvent(&messageEvent)
+		}
+		if messageEvent.User == "" {
+			return nil
+		}
+
+		botEvent.Type = bot.MessageEvent
+		botEvent.Message = messageEvent.Text
+		botEvent.Channel = messageEvent.Channel
+		botEvent.User.Id = messageEvent.User
+		botEvent.Ts = messageEvent.Ts
+		botEvent.ThreadTs = messageEvent.ThreadTs
+	case "user_typing":
+		var userTypingEvent UserTyping
+		if err := json.Unmarshal(buf, &userTypingEvent); err != nil {
+			return nil
+		}
+		if userTypingEvent.User == "" {
+			return nil
+		}
+
+		botEvent.Type = bot.UserTypingEvent
+		botEvent.Channel = userTypingEvent.Channel
+		botEvent.User.Id = userTypingEvent.User
+	case "pong":
+		return nil
+	case "reaction_added":
+		botEvent.Type = bot.ReactionAddedEvent
+		reactionAdded := new(ReactionAdded)
+		if err := json.Unmarshal(buf, reactionAdded); err != nil {
+			return nil
+		}
+		if reactionAdded.Item.Type != "message" {
+			return nil
+		}
+		botEvent.Channel = reactionAdded.Item.Channel
+		botEvent.Ts = reactionAdded.Item.Ts
+		botEvent.User.Id = reactionAdded.User
+		botEvent.Reaction = reactionAdded.Reaction
+	case "reaction_removed":
+		botEvent.Type = bot.ReactionRemovedEvent
+		reactionRemoved := new(ReactionRemoved)
+		if err := json.Unmarshal(buf, reactionRemoved); err != nil {
+			return nil
+		}
+		if reactionRemoved.Item.Type != "message" {
+			return nil
+		}
+		botEvent.Channel = reactionRemoved.Item.Channel
+		botEvent.Ts = reactionRemoved.Item.Ts
+		botEvent.User.Id = reactionRemoved.User
+		botEvent.Reaction = reactionRemoved.Reaction
+	case "member_joined_channel":
+		var joined MemberJoinedChannel
+		if err := json.Unmarshal(buf, &joined); err != nil {
+			return nil
+		}
+
+		botEvent.Type = bot.MemberJoinedChannelEvent
+		botEvent.Channel = joined.Channel
+		botEvent.User.Id = joined.User
+	case "channel_created":
+		var created ChannelCreated
+		if err := json.Unmarshal(buf, &created); err != nil {
+			return nil
+		}
+
+		botEvent.Type = bot.ChannelCreatedEvent
+		botEvent.Channel = created.Channel.Id
+		botEvent.User.Id = created.Channel.Creator
+	default:
+		botEvent.Type = bot.UnknownEvent
+	}
+
+	return botEvent
+}
+
+// toMessageChangedEvent converts the message_changed event.
+// It returns nil if the text isn't changed, e.g. when the link in the message is unfurled.
+func toMessageChangedEvent(m *Message) *bot.Event {
+	if m.Message == nil || m.Message.User == "" {
+		return nil
+	}
+	previous := ""
+	if m.PreviousMessage != nil {
+		previous = m.PreviousMessage.Text
+	}
+	if m.Message.Text == previous {
+		return nil
+	}
+
+	botEvent := &bot.Event{
+		Type:            bot.MessageChangedEvent,
+		Message:         m.Message.Text,
+		PreviousMessage: previous,
+		Channel:         m.Channel,
+		Ts:              m.Message.Ts,
+		ThreadTs:        m.Message.ThreadTs,
+	}
+	botEvent.User.Id = m.Message.User
+
+	return botEvent
+}
+
+// toMessageDeletedEvent converts the message_deleted event. The user of the event is the author of the message.
+func toMessageDeletedEvent(m *Message) *bot.Event {
+	if m.PreviousMessage == nil || m.PreviousMessage.User == "" {
+		return nil
+	}
+
+	botEvent := &bot.Event{
+		Type:            bot.MessageDeletedEvent,
+		PreviousMessage: m.PreviousMessage.Text,
+		Channel:         m.Channel,
+		Ts:              m.DeletedTs,
+		ThreadTs:        m.PreviousMessage.ThreadTs,
+	}
+	botEvent.User.Id = m.PreviousMessage.User
+
+	return botEvent
+}
+
+// toInteractionEvent converts a payload of interactive components into bot.Event.
+// It returns nil if the payload doesn't have any action.
+func toInteractionEvent(buf []byte) *bot.Event {
+	var interaction Interaction
+	if err := json.Unmarshal(buf, &interaction); err != nil {
+		return nil
+	}
+	if len(interaction.Actions) == 0 {
+		return nil
+	}
+
+	a := interaction.Actions[0]
+	action := &bot.Action{Id: a.ActionId, BlockId: a.BlockId, Value: a.Value}
+	if action.Id == "" {
+		action.Id = a.Name
+		action.BlockId = interaction.CallbackId
+	}
+	if action.Value == "" {
+		action.Value = a.SelectedOption.Value
+	}
+	if action.Value == "" && len(a.SelectedOptions) > 0 {
+		action.Value = a.SelectedOptions[0].Value
+	}
+
+	botEvent := &bot.Event{
+		Type:    bot.InteractionEvent,
+		Channel: interaction.Channel.Id,
+		Ts:      interaction.Container.MessageTs,
+		Action:  action,
+	}
+	botEvent.User.Id = interaction.User.Id
+	if botEvent.Ts == "" {
+		botEvent.Ts = interaction.MessageTs
+	}
+
+	return botEvent
+}
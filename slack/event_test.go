@@ -0,0 +1,46 @@
+package slack
+
+import (
+	"testing"
+)
+
+func TestToInteractionEvent(t *testing.T) {
+	cases := []struct {
+		Name    string
+		Payload string
+		Id      string
+		Value   string
+	}{
+		{
+			Name:    "button",
+			Payload: `{"type":"block_actions","user":{"id":"U1"},"channel":{"id":"C1"},"container":{"message_ts":"1.1"},"actions":[{"action_id":"approve","block_id":"b1","value":"yes"}]}`,
+			Id:      "approve",
+			Value:   "yes",
+		},
+		{
+			Name:    "static_select",
+			Payload: `{"type":"block_actions","user":{"id":"U1"},"channel":{"id":"C1"},"container":{"message_ts":"1.1"},"actions":[{"action_id":"env","selected_option":{"value":"prod"}}]}`,
+			Id:      "env",
+			Value:   "prod",
+		},
+		{
+			Name:    "legacy menu",
+			Payload: `{"type":"interactive_message","callback_id":"deploy","user":{"id":"U1"},"channel":{"id":"C1"},"message_ts":"1.1","actions":[{"name":"env","type":"select","selected_options":[{"value":"staging"}]}]}`,
+			Id:      "env",
+			Value:   "staging",
+		},
+	}
+
+	for _, c := range cases {
+		event := toInteractionEvent([]byte(c.Payload))
+		if event == nil {
+			t.Fatalf("%s: the payload is ignored", c.Name)
+		}
+		if event.Action.Id != c.Id || event.Action.Value != c.Value {
+			t.Errorf("%s: unexpected action: %+v", c.Name, event.Action)
+		}
+		if event.Channel != "C1" || event.Ts != "1.1" || event.User.Id != "U1" {
+			t.Errorf("%s: unexpected event: %+v", c.Name, event)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/f110/montegrappa/bot"
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+)
+
+var (
+	// EventQueueSize is the number of the requests which are waiting for the conversion into bot.Event.
+	// The request is answered with 503 while the queue is full, and Slack retries it later.
+	EventQueueSize = 100
+)
+
+const (
+	// handledEventTTL is the time to remember event_id for dropping the retries of Slack.
+	handledEventTTL = time.Hour
+)
+
+// EventsAPIConnector receives events through the Events API instead of the RTM API.
+// Slack delivers events to the HTTP endpoint which is served by the connector.
+// The request is answered before the event is handled because Slack requires the response within 3 seconds.
+type EventsAPIConnector struct {
+	webClient
+
+	addr          string
+	signingSecret string
+	server        *http.Server
+	eventChan     chan *bot.Event
+	errorChan     chan error
+	// queue has the conversions of the requests which have been answered.
+	queue chan func() *bot.Event
+	// handled is the time when the event_id was received.
+	handled map[string]time.Time
+	mutex   sync.Mutex
+}
+
+type outerEvent struct {
+	Type string `json:"type"`
+}
+
+// NewEventsAPIConnector returns the connector which listens on addr.
+// signingSecret is used for verifying X-Slack-Signature of each request.
+func NewEventsAPIConnector(teamId, token, signingSecret, addr string) *EventsAPIConnector {
+	connector := &EventsAPIConnector{
+		webClient:     newWebClient(teamId, token),
+		addr:          addr,
+		signingSecret: signingSecret,
+		eventChan:     make(chan *bot.Event),
+		errorChan:     make(chan error),
+		queue:         make(chan func() *bot.Event, EventQueueSize),
+		handled:       make(map[string]time.Time),
+	}
+	go connector.dispatch()
+
+	return connector
+}
+
+func (connector *EventsAPIConnector) Connect() error {
+	l, err := net.Listen("tcp", connector.addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("start listening on %s", l.Addr())
+
+	connector.server = &http.Server{Handler: connector}
+	go func() {
+		connector.errorChan <- connector.server.Serve(l)
+	}()
+
+	return nil
+}
+
+func (connector *EventsAPIConnector) Listen() error {
+	return <-connector.errorChan
+}
+
+func (*EventsAPIConnector) Async() bool {
+	return true
+}
+
+func (connector *EventsAPIConnector) ReceivedEvent() chan *bot.Event {
+	return connector.eventChan
+}
+
+func (connector *EventsAPIConnector) Idle() chan bool {
+	return nil
+}
+
+// WithIndicate does nothing because the typing indicator is only available on RTM API.
+func (connector *EventsAPIConnector) WithIndicate(_ string) context.CancelFunc {
+	_, cancel := context.WithCancel(context.Background())
+	return cancel
+}
+
+func (connector *EventsAPIConnector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := verifyRequest(req.Header, body, connector.signingSecret); err != nil {
+		log.Printf("invalid request: %s", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		connector.serveForm(w, body)
+		return
+	}
+
+	var outer outerEvent
+	if err := json.Unmarshal(body, &outer); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch outer.Type {
+	case slackevents.URLVerification:
+		var verification slackevents.EventsAPIURLVerificationEvent
+		if err := json.Unmarshal(body, &verification); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(verification.Challenge))
+	case slackevents.CallbackEvent:
+		var callback slackevents.EventsAPICallbackEvent
+		if err := json.Unmarshal(body, &callback); err != nil || callback.InnerEvent == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !connector.markHandled(callback.EventID) {
+			log.Printf("drop the event %s which has been handled (retry: %s)", callback.EventID, req.Header.Get("X-Slack-Retry-Num"))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		inner := *callback.InnerEvent
+		if !connector.enqueue(func() *bot.Event { return connector.toBotEvent(inner) }) {
+			connector.forget(callback.EventID)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// serveForm handles the payload of interactive components and the slash command, which are sent as the form.
+func (connector *EventsAPIConnector) serveForm(w http.ResponseWriter, body []byte) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if values.Get("command") != "" {
+		connector.serveSlashCommand(w, values)
+		return
+	}
+	payload := []byte(values.Get("payload"))
+	if len(payload) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !connector.enqueue(func() *bot.Event { return connector.toInteractionEvent(payload) }) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// serveSlashCommand passes the slash command to the bot and responds with the acknowledgement of the handler.
+// The response is sent within bot.SlashCommandAckTimeout even if the bot is busy.
+func (connector *EventsAPIConnector) serveSlashCommand(w http.ResponseWriter, values url.Values) {
+	botEvent := toSlashCommandEvent(&SlashCommandPayload{
+		Command:     values.Get("command"),
+		Text:        values.Get("text"),
+		UserId:      values.Get("user_id"),
+		ChannelId:   values.Get("channel_id"),
+		ResponseURL: values.Get("response_url"),
+		TriggerId:   values.Get("trigger_id"),
+	})
+	if !connector.enqueue(func() *bot.Event { return connector.resolve(botEvent) }) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	text := botEvent.SlashCommand.WaitAck(bot.SlashCommandAckTimeout)
+	if text == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newSlashCommandResponse(text, false))
+}
+
+// enqueue adds the conversion to the queue. It returns false if the queue is full.
+func (connector *EventsAPIConnector) enqueue(f func() *bot.Event) bool {
+	select {
+	case connector.queue <- f:
+		return true
+	default:
+		log.Print("the event queue is full")
+		return false
+	}
+}
+
+// dispatch converts the requests into bot.Event in order and passes them to the bot.
+func (connector *EventsAPIConnector) dispatch() {
+	for f := range connector.queue {
+		if botEvent := f(); botEvent != nil {
+			connector.eventChan <- botEvent
+		}
+	}
+}
+
+// markHandled records eventId. It returns false if eventId has been received already.
+func (connector *EventsAPIConnector) markHandled(eventId string) bool {
+	if eventId == "" {
+		return true
+	}
+
+	connector.mutex.Lock()
+	defer connector.mutex.Unlock()
+
+	now := time.Now()
+	for id, t := range connector.handled {
+		if now.Sub(t) > handledEventTTL {
+			delete(connector.handled, id)
+		}
+	}
+	if _, ok := connector.handled[eventId]; ok {
+		return false
+	}
+	connector.handled[eventId] = now
+
+	return true
+}
+
+// forget removes eventId so that the retry of the event is accepted.
+func (connector *EventsAPIConnector) forget(eventId string) {
+	connector.mutex.Lock()
+	defer connector.mutex.Unlock()
+
+	delete(connector.handled, eventId)
+}
+
+func verifyRequest(header http.Header, body []byte, signingSecret string) error {
+	verifier, err := slack.NewSecretsVerifier(header, signingSecret)
+	if err != nil {
+		return err
+	}
+	if _, err := verifier.Write(body); err != nil {
+		return err
+	}
+
+	return verifier.Ensure()
+}
@@ -0,0 +1,118 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/f110/montegrappa/bot"
+	"github.com/f110/montegrappa/slack/slacktest"
+)
+
+const testSigningSecret = "signing-secret"
+
+func TestEventsAPIConnector_URLVerification(t *testing.T) {
+	connector := NewEventsAPIConnector("T1", "xoxb-token", testSigningSecret, "")
+
+	res := serveSigned(connector, `{"type":"url_verification","challenge":"challenge-value"}`, "application/json", time.Now(), nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", res.Code)
+	}
+	if res.Body.String() != "challenge-value" {
+		t.Fatalf("unexpected challenge: %s", res.Body.String())
+	}
+}
+
+func TestEventsAPIConnector_InvalidSignature(t *testing.T) {
+	connector := NewEventsAPIConnector("T1", "xoxb-token", testSigningSecret, "")
+	body := `{"type":"url_verification","challenge":"challenge-value"}`
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	req.Header.Set("X-Slack-Signature", "v0=0123456789abcdef")
+	res := httptest.NewRecorder()
+	connector.ServeHTTP(res, req)
+	if res.Code != http.StatusUnauthorized {
+		t.Fatalf("the request which has the bad signature is accepted: %d", res.Code)
+	}
+
+	res = serveSigned(connector, body, "application/json", time.Now().Add(-10*time.Minute), nil)
+	if res.Code != http.StatusUnauthorized {
+		t.Fatalf("the stale request is accepted: %d", res.Code)
+	}
+}
+
+func TestEventsAPIConnector_EventCallback(t *testing.T) {
+	server := slacktest.NewServer()
+	defer server.Close()
+	server.Users["U1"] = slacktest.User{Name: "alice"}
+
+	connector := NewEventsAPIConnector("T1", "xoxb-token", testSigningSecret, "")
+	connector.SetAPIURL(server.APIURL())
+	body := `{"type":"event_callback","event_id":"Ev1","event":{"type":"message","channel":"C1","user":"U1","text":"hello","ts":"1.000001"}}`
+
+	// The request is answered although nobody receives the event yet.
+	res := serveSigned(connector, body, "application/json", time.Now(), nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", res.Code)
+	}
+	retry := http.Header{"X-Slack-Retry-Num": []string{"1"}, "X-Slack-Retry-Reason": []string{"http_timeout"}}
+	res = serveSigned(connector, body, "application/json", time.Now(), retry)
+	if res.Code != http.StatusOK {
+		t.Fatalf("unexpected status of the retry: %d", res.Code)
+	}
+
+	event := receiveEvent(t, connector.ReceivedEvent())
+	if event.Type != bot.MessageEvent || event.Message != "hello" || event.User.Name != "alice" {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	select {
+	case event := <-connector.ReceivedEvent():
+		t.Fatalf("the retry is delivered: %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestEventsAPIConnector_SlashCommand(t *testing.T) {
+	connector := NewEventsAPIConnector("T1", "xoxb-token", testSigningSecret, "")
+	body := url.Values{"command": {"/deploy"}, "text": {"prod"}, "user_id": {"U1"}, "channel_id": {"C1"}}.Encode()
+
+	go func() {
+		event := <-connector.ReceivedEvent()
+		event.SlashCommand.Ack("deploying")
+	}()
+	res := serveSigned(connector, body, "application/x-www-form-urlencoded", time.Now(), nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", res.Code)
+	}
+	if !strings.Contains(res.Body.String(), "deploying") {
+		t.Fatalf("the response doesn't have the acknowledgement: %s", res.Body.String())
+	}
+}
+
+// serveSigned signs body with testSigningSecret at ts and serves it by the connector.
+func serveSigned(connector *EventsAPIConnector, body, contentType string, ts time.Time, header http.Header) *httptest.ResponseRecorder {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(testSigningSecret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	res := httptest.NewRecorder()
+	connector.ServeHTTP(res, req)
+
+	return res
+}
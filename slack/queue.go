@@ -0,0 +1,187 @@
+package slack
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+var (
+	// MaxRetry is the number of the retries for the transient failure and the rate limit.
+	MaxRetry = 5
+	// RetryBackoff is the wait before the first retry. It doubles on each retry.
+	RetryBackoff = 1 * time.Second
+	// QueueSize is the number of the messages which can wait in the queue of a channel.
+	QueueSize = 100
+
+	// rateLimits is the rate of the methods which is derived from the tiers of the Web API.
+	// chat.postMessage is limited per channel, and the others are limited per workspace.
+	rateLimits = map[string]rateLimit{
+		"chat.postMessage":   {every: time.Second, burst: 5},
+		"files.upload":       {every: 3 * time.Second, burst: 5},          // Tier 2: 20+ per minute
+		"conversations.open": {every: 1200 * time.Millisecond, burst: 10}, // Tier 3: 50+ per minute
+		"chat.update":        {every: 1200 * time.Millisecond, burst: 10}, // Tier 3: 50+ per minute
+		"chat.delete":        {every: 1200 * time.Millisecond, burst: 10}, // Tier 3: 50+ per minute
+		"chat.postEphemeral": {every: 600 * time.Millisecond, burst: 20},  // Tier 4: 100+ per minute
+	}
+)
+
+// OnSendFailure is called when the message is not sent because of the permanent error or too many retries.
+type OnSendFailure func(method, channel string, err error)
+
+type rateLimit struct {
+	every time.Duration
+	burst int
+}
+
+// bucket is the token bucket of rateLimit.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// outboundQueue sends the requests of each channel in order, one at a time.
+// The requests are throttled by rateLimits and retried on the rate limit and the transient failure.
+type outboundQueue struct {
+	onFailure OnSendFailure
+	workers   map[string]chan *outboundJob
+	buckets   map[string]*bucket
+	mutex     sync.Mutex
+}
+
+type outboundJob struct {
+	method  string
+	channel string
+	call    func() (string, error)
+	result  chan outboundResult
+}
+
+type outboundResult struct {
+	ts  string
+	err error
+}
+
+func newOutboundQueue() *outboundQueue {
+	return &outboundQueue{workers: make(map[string]chan *outboundJob), buckets: make(map[string]*bucket)}
+}
+
+// do enqueues the call to the queue of the channel and waits for the result.
+func (q *outboundQueue) do(method, channel string, call func() (string, error)) (string, error) {
+	job := &outboundJob{method: method, channel: channel, call: call, result: make(chan outboundResult, 1)}
+	q.worker(channel) <- job
+
+	r := <-job.result
+	return r.ts, r.err
+}
+
+func (q *outboundQueue) worker(channel string) chan *outboundJob {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if w, ok := q.workers[channel]; ok {
+		return w
+	}
+	w := make(chan *outboundJob, QueueSize)
+	q.workers[channel] = w
+	go func() {
+		for job := range w {
+			ts, err := q.run(job)
+			job.result <- outboundResult{ts: ts, err: err}
+		}
+	}()
+
+	return w
+}
+
+func (q *outboundQueue) run(job *outboundJob) (string, error) {
+	backoff := RetryBackoff
+	for retry := 0; ; retry++ {
+		time.Sleep(q.reserve(job.method, job.channel))
+
+		ts, err := job.call()
+		if err == nil {
+			return ts, nil
+		}
+		if retry >= MaxRetry {
+			q.fail(job, err)
+			return "", err
+		}
+
+		var rateLimited *slack.RateLimitedError
+		switch {
+		case errors.As(err, &rateLimited):
+			log.Printf("%s to %s is rate limited. retry after %s", job.method, job.channel, rateLimited.RetryAfter)
+			time.Sleep(rateLimited.RetryAfter)
+		case isTransient(err):
+			log.Printf("%s to %s failed, retry after %s: %s", job.method, job.channel, backoff, err)
+			time.Sleep(backoff)
+			backoff *= 2
+		default:
+			q.fail(job, err)
+			return "", err
+		}
+	}
+}
+
+// reserve takes a token of the method and returns the time to wait for it.
+func (q *outboundQueue) reserve(method, channel string) time.Duration {
+	limit, ok := rateLimits[method]
+	if !ok {
+		return 0
+	}
+	key := method
+	if method == "chat.postMessage" {
+		key += ":" + channel
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	b, ok := q.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.burst), last: now}
+		q.buckets[key] = b
+	}
+	b.tokens += float64(now.Sub(b.last)) / float64(limit.every)
+	if b.tokens > float64(limit.burst) {
+		b.tokens = float64(limit.burst)
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens * float64(limit.every))
+}
+
+func (q *outboundQueue) fail(job *outboundJob, err error) {
+	log.Printf("failed to %s to %s: %s", job.method, job.channel, err)
+	if q.onFailure != nil {
+		q.onFailure(job.method, job.channel, err)
+	}
+}
+
+// isTransient reports whether the request may succeed by the retry.
+func isTransient(err error) bool {
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) {
+		return status.HTTPStatusCode() >= 500
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	switch err.Error() {
+	case "internal_error", "fatal_error", "service_unavailable", "request_timeout":
+		return true
+	}
+
+	return false
+}
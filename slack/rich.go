@@ -0,0 +1,286 @@
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/f110/montegrappa/bot"
+	"github.com/nlopes/slack"
+)
+
+// apiResponse is the common part of the response of the Web API.
+type apiResponse struct {
+	Ok      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	Ts      string `json:"ts"`
+}
+
+// statusCodeError is the error of the response which isn't 200 OK.
+type statusCodeError struct {
+	Code   int
+	Status string
+}
+
+// SendRich posts the message as Block Kit.
+// The Web API client doesn't support Block Kit, so the message is posted by callAPI.
+func (w *webClient) SendRich(event *bot.Event, username, threadTs string, message *bot.RichMessage) (*bot.SentMessage, error) {
+	values, err := richMessageValues(message)
+	if err != nil {
+		return nil, err
+	}
+	values.Set("channel", event.Channel)
+	values.Set("username", username)
+	if threadTs != "" {
+		values.Set("thread_ts", threadTs)
+	}
+
+	ts, err := w.queue.do("chat.postMessage", event.Channel, func() (string, error) {
+		res, err := w.callAPI("chat.postMessage", values)
+		if err != nil {
+			return "", err
+		}
+		return res.Ts, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &bot.SentMessage{Message: values.Get("text"), Channel: event.Channel, Timestamp: ts}, nil
+}
+
+// UpdateRich replaces the message with the blocks.
+// The blocks of the message are removed if the new message doesn't have any block.
+func (w *webClient) UpdateRich(message *bot.SentMessage, rich *bot.RichMessage) (*bot.SentMessage, error) {
+	values, err := richMessageValues(rich)
+	if err != nil {
+		return nil, err
+	}
+	values.Set("channel", message.Channel)
+	values.Set("ts", message.Timestamp)
+	if values.Get("attachments") == "" {
+		values.Set("attachments", "[]")
+	}
+
+	_, err = w.queue.do("chat.update", message.Channel, func() (string, error) {
+		res, err := w.callAPI("chat.update", values)
+		if err != nil {
+			return "", err
+		}
+		return res.Ts, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &bot.SentMessage{Message: values.Get("text"), Channel: message.Channel, Timestamp: message.Timestamp}, nil
+}
+
+// callAPI calls the method of the Web API with the form values.
+// The error is compatible with the error of the Web API client, so that outboundQueue can retry it.
+func (w *webClient) callAPI(method string, values url.Values) (*apiResponse, error) {
+	values.Set("token", w.token)
+	res, err := w.httpClient.PostForm(w.endpoint(method), values)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		// The request is retried after RetryBackoff if Retry-After is missing or invalid.
+		retry, err := strconv.ParseInt(res.Header.Get("Retry-After"), 10, 64)
+		if err != nil || retry < 0 {
+			return nil, &slack.RateLimitedError{RetryAfter: RetryBackoff}
+		}
+		return nil, &slack.RateLimitedError{RetryAfter: time.Duration(retry) * time.Second}
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, statusCodeError{Code: res.StatusCode, Status: res.Status}
+	}
+
+	var r apiResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	if !r.Ok {
+		return nil, errors.New(r.Error)
+	}
+
+	return &r, nil
+}
+
+// RenderBlockKit renders the message to the JSON of blocks and attachments.
+// attachments is nil if the message doesn't have any attachment.
+func RenderBlockKit(message *bot.RichMessage) (blocks []byte, attachments []byte, err error) {
+	blocks, err = json.Marshal(renderBlocks(message.Blocks))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(message.Attachments) == 0 {
+		return blocks, nil, nil
+	}
+
+	a := make([]map[string]interface{}, 0, len(message.Attachments))
+	for _, attachment := range message.Attachments {
+		a = append(a, map[string]interface{}{
+			"color":    attachment.Color,
+			"fallback": (&bot.RichMessage{Blocks: attachment.Blocks}).PlainText(),
+			"blocks":   renderBlocks(attachment.Blocks),
+		})
+	}
+	attachments, err = json.Marshal(a)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return blocks, attachments, nil
+}
+
+func richMessageValues(message *bot.RichMessage) (url.Values, error) {
+	blocks, attachments, err := RenderBlockKit(message)
+	if err != nil {
+		return nil, err
+	}
+
+	values := url.Values{}
+	values.Set("blocks", string(blocks))
+	if attachments != nil {
+		values.Set("attachments", string(attachments))
+	}
+	// text is shown in the notification. Slack requires it when the blocks are posted.
+	text := message.Text
+	if text == "" {
+		text = message.PlainText()
+	}
+	values.Set("text", text)
+
+	return values, nil
+}
+
+func renderBlocks(blocks []bot.Block) []map[string]interface{} {
+	res := make([]map[string]interface{}, 0, len(blocks))
+	for _, b := range blocks {
+		var block map[string]interface{}
+		switch b := b.(type) {
+		case *bot.Section:
+			block = map[string]interface{}{"type": "section"}
+			if b.Text != "" {
+				block["text"] = markdown(b.Text)
+			}
+			if len(b.Fields) > 0 {
+				fields := make([]map[string]interface{}, 0, len(b.Fields))
+				for _, f := range b.Fields {
+					fields = append(fields, markdown(f))
+				}
+				block["fields"] = fields
+			}
+			if b.Accessory != nil {
+				block["accessory"] = renderElement(b.Accessory)
+			}
+			setBlockId(block, b.BlockId)
+		case *bot.Divider:
+			block = map[string]interface{}{"type": "divider"}
+		case *bot.Image:
+			block = renderElement(b)
+			if b.Title != "" {
+				block["title"] = plainText(b.Title)
+			}
+			setBlockId(block, b.BlockId)
+		case *bot.Context:
+			elements := make([]map[string]interface{}, 0, len(b.Elements))
+			for _, e := range b.Elements {
+				elements = append(elements, markdown(e))
+			}
+			block = map[string]interface{}{"type": "context", "elements": elements}
+			setBlockId(block, b.BlockId)
+		case *bot.Actions:
+			elements := make([]map[string]interface{}, 0, len(b.Elements))
+			for _, e := range b.Elements {
+				elements = append(elements, renderElement(e))
+			}
+			block = map[string]interface{}{"type": "actions", "elements": elements}
+			setBlockId(block, b.BlockId)
+		default:
+			continue
+		}
+		res = append(res, block)
+	}
+
+	return res
+}
+
+func renderElement(e bot.Element) map[string]interface{} {
+	switch e := e.(type) {
+	case *bot.Image:
+		alt := e.AltText
+		if alt == "" {
+			alt = e.Title
+		}
+		if alt == "" {
+			alt = e.URL
+		}
+		return map[string]interface{}{"type": "image", "image_url": e.URL, "alt_text": alt}
+	case *bot.Button:
+		button := map[string]interface{}{"type": "button", "text": plainText(e.Text)}
+		if e.ActionId != "" {
+			button["action_id"] = e.ActionId
+		}
+		if e.Value != "" {
+			button["value"] = e.Value
+		}
+		if e.Style != bot.ButtonDefault {
+			button["style"] = e.Style
+		}
+		if e.URL != "" {
+			button["url"] = e.URL
+		}
+		return button
+	case *bot.Select:
+		options := make([]map[string]interface{}, 0, len(e.Options))
+		var initial map[string]interface{}
+		for _, o := range e.Options {
+			option := map[string]interface{}{"text": plainText(o.Text), "value": o.Value}
+			if e.Initial != "" && o.Value == e.Initial {
+				initial = option
+			}
+			options = append(options, option)
+		}
+		s := map[string]interface{}{"type": "static_select", "placeholder": plainText(e.Placeholder), "options": options}
+		if e.ActionId != "" {
+			s["action_id"] = e.ActionId
+		}
+		if initial != nil {
+			s["initial_option"] = initial
+		}
+		return s
+	}
+
+	return nil
+}
+
+func setBlockId(block map[string]interface{}, id string) {
+	if id != "" {
+		block["block_id"] = id
+	}
+}
+
+func markdown(text string) map[string]interface{} {
+	return map[string]interface{}{"type": "mrkdwn", "text": text}
+}
+
+func plainText(text string) map[string]interface{} {
+	return map[string]interface{}{"type": "plain_text", "text": text}
+}
+
+func (e statusCodeError) Error() string {
+	return fmt.Sprintf("slack server error: %s", e.Status)
+}
+
+func (e statusCodeError) HTTPStatusCode() int {
+	return e.Code
+}
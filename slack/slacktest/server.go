@@ -0,0 +1,588 @@
+// Package slacktest provides the in-process fake of the Slack Web API, RTM and Socket Mode for testing the connectors offline.
+package slacktest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var (
+	// ConnectionTimeout is the time which SendEvent waits for the websocket connection.
+	ConnectionTimeout = 5 * time.Second
+
+	ErrNotConnected = errors.New("slacktest: no websocket connection")
+)
+
+// Message is the message which is posted by chat.postMessage or chat.postEphemeral.
+type Message struct {
+	Channel  string
+	Username string
+	Text     string
+	ThreadTs string
+	Ts       string
+	// User is the receiver of the ephemeral message.
+	User      string
+	Ephemeral bool
+	// Blocks and Attachments are the JSON which is posted with the message.
+	Blocks      string
+	Attachments string
+}
+
+// File is the file which is uploaded by files.upload.
+type File struct {
+	Channels []string
+	Filename string
+	Title    string
+	Content  []byte
+}
+
+// Response is the response of the slash command which is sent to ResponseURL.
+type Response struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// User is the user which is returned by users.info.
+type User struct {
+	Id          string
+	Name        string
+	RealName    string
+	DisplayName string
+	TimeZone    string
+	IsBot       bool
+	Deleted     bool
+}
+
+// Ack is the acknowledgement of the envelope which is sent over Socket Mode.
+type Ack struct {
+	EnvelopeId string          `json:"envelope_id"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Failure is the response which is returned instead of the result of the method.
+type Failure struct {
+	// Status is the HTTP status code. If it is 0, the error is returned with 200 OK.
+	Status int
+	// Error is the error code like "channel_not_found".
+	Error string
+	// RetryAfter is set to Retry-After header when Status is 429.
+	// If RetryAfter is zero, the response doesn't have Retry-After header.
+	RetryAfter time.Duration
+}
+
+// Server is the fake of the Slack API.
+//
+//	server := slacktest.NewServer()
+//	defer server.Close()
+//	connector := slack.NewConnector("T1", "xoxb-token")
+//	connector.SetAPIURL(server.APIURL())
+type Server struct {
+	// TeamId and TeamDomain are returned by team.info.
+	TeamId     string
+	TeamDomain string
+	// Channels is the names of the channels which are returned by conversations.info.
+	Channels map[string]string
+	// Users is the users which are returned by users.info.
+	Users map[string]User
+
+	server      *httptest.Server
+	messages    []Message
+	files       []File
+	responses   []Response
+	connections []*websocket.Conn
+	sockets     []*websocket.Conn
+	acks        []Ack
+	opens       int
+	failures    map[string][]Failure
+	seq         int
+	connected   *sync.Cond
+	mutex       sync.Mutex
+}
+
+func NewServer() *Server {
+	s := &Server{
+		TeamId:      "T00000000",
+		TeamDomain:  "slacktest",
+		Channels:    make(map[string]string),
+		Users:       make(map[string]User),
+		messages:    make([]Message, 0),
+		files:       make([]File, 0),
+		responses:   make([]Response, 0),
+		connections: make([]*websocket.Conn, 0),
+		sockets:     make([]*websocket.Conn, 0),
+		acks:        make([]Ack, 0),
+		failures:    make(map[string][]Failure),
+	}
+	s.connected = sync.NewCond(&s.mutex)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth.test", s.handle("auth.test", s.authTest))
+	mux.HandleFunc("/api/chat.postMessage", s.handle("chat.postMessage", s.postMessage))
+	mux.HandleFunc("/api/chat.postEphemeral", s.handle("chat.postEphemeral", s.postEphemeral))
+	mux.HandleFunc("/api/chat.update", s.handle("chat.update", s.updateMessage))
+	mux.HandleFunc("/api/chat.delete", s.handle("chat.delete", s.deleteMessage))
+	mux.HandleFunc("/api/conversations.open", s.handle("conversations.open", s.openConversation))
+	mux.HandleFunc("/api/conversations.info", s.handle("conversations.info", s.conversationInfo))
+	mux.HandleFunc("/api/files.upload", s.handle("files.upload", s.uploadFile))
+	mux.HandleFunc("/api/team.info", s.handle("team.info", s.teamInfo))
+	mux.HandleFunc("/api/users.info", s.handle("users.info", s.userInfo))
+	mux.HandleFunc("/api/rtm.connect", s.handle("rtm.connect", s.connectRTM))
+	mux.HandleFunc("/api/apps.connections.open", s.handle("apps.connections.open", s.openConnection))
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, map[string]interface{}{"ok": false, "error": "unknown_method"})
+	})
+	mux.HandleFunc("/response", s.response)
+	mux.Handle("/ws", websocket.Handler(s.serveWebsocket))
+	mux.Handle("/socket", websocket.Handler(s.serveSocketMode))
+	s.server = httptest.NewServer(mux)
+
+	return s
+}
+
+// APIURL returns the base URL of the Web API which is passed to SetAPIURL of the connector.
+func (s *Server) APIURL() string {
+	return s.server.URL + "/api/"
+}
+
+// ResponseURL returns the URL which is used as response_url of the slash command.
+func (s *Server) ResponseURL() string {
+	return s.server.URL + "/response"
+}
+
+func (s *Server) Close() {
+	s.mutex.Lock()
+	for _, conn := range s.connections {
+		conn.Close()
+	}
+	for _, conn := range s.sockets {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+
+	s.server.Close()
+}
+
+// Fail makes the following calls of the method fail in order.
+func (s *Server) Fail(method string, failures ...Failure) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.failures[method] = append(s.failures[method], failures...)
+}
+
+// Messages returns the messages which have been posted.
+// The messages which are updated by chat.update have the latest content, and the deleted messages are not included.
+func (s *Server) Messages() []Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	messages := make([]Message, len(s.messages))
+	copy(messages, s.messages)
+	return messages
+}
+
+// Files returns the files which have been uploaded.
+func (s *Server) Files() []File {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	files := make([]File, len(s.files))
+	copy(files, s.files)
+	return files
+}
+
+// Responses returns the responses which have been sent to ResponseURL.
+func (s *Server) Responses() []Response {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	responses := make([]Response, len(s.responses))
+	copy(responses, s.responses)
+	return responses
+}
+
+// SendEvent sends the event to all websocket connections.
+// It waits for the connection up to ConnectionTimeout.
+func (s *Server) SendEvent(event interface{}) error {
+	return s.broadcast(&s.connections, event)
+}
+
+// SendMessage sends the message event from the user.
+// The timestamp of the message is returned.
+func (s *Server) SendMessage(channel, user, text string) (string, error) {
+	ts := s.nextTs()
+	return ts, s.SendEvent(map[string]string{"type": "message", "channel": channel, "user": user, "text": text, "ts": ts})
+}
+
+// SendEnvelope sends the payload to all Socket Mode connections as the envelope of the type,
+// e.g. "events_api", "interactive" or "slash_commands".
+// It waits for the connection up to ConnectionTimeout, and the id of the envelope is returned.
+func (s *Server) SendEnvelope(envelopeType string, payload interface{}) (string, error) {
+	s.mutex.Lock()
+	s.seq++
+	id := fmt.Sprintf("envelope-%d", s.seq)
+	s.mutex.Unlock()
+
+	return id, s.broadcast(&s.sockets, map[string]interface{}{"type": envelopeType, "envelope_id": id, "payload": payload})
+}
+
+// Acks returns the acknowledgements which have been sent over Socket Mode.
+func (s *Server) Acks() []Ack {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	acks := make([]Ack, len(s.acks))
+	copy(acks, s.acks)
+	return acks
+}
+
+// Opens returns the number of the calls of apps.connections.open.
+func (s *Server) Opens() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.opens
+}
+
+// DropSockets closes all Socket Mode connections from the server side.
+func (s *Server) DropSockets() {
+	s.mutex.Lock()
+	sockets := s.sockets
+	s.sockets = make([]*websocket.Conn, 0)
+	s.mutex.Unlock()
+
+	for _, conn := range sockets {
+		conn.Close()
+	}
+}
+
+// handle returns the failure which is set by Fail instead of calling f.
+func (s *Server) handle(method string, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		s.mutex.Lock()
+		failures := s.failures[method]
+		if len(failures) == 0 {
+			s.mutex.Unlock()
+			f(w, req)
+			return
+		}
+		failure := failures[0]
+		s.failures[method] = failures[1:]
+		s.mutex.Unlock()
+
+		switch failure.Status {
+		case 0, http.StatusOK:
+			writeJSON(w, map[string]interface{}{"ok": false, "error": failure.Error})
+		case http.StatusTooManyRequests:
+			if failure.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(failure.RetryAfter/time.Second)))
+			}
+			w.WriteHeader(failure.Status)
+		default:
+			w.WriteHeader(failure.Status)
+		}
+	}
+}
+
+func (s *Server) authTest(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]interface{}{"ok": true, "team_id": s.TeamId, "team": s.TeamDomain, "user_id": "U00000000", "user": "bot"})
+}
+
+func (s *Server) postMessage(w http.ResponseWriter, req *http.Request) {
+	m := Message{
+		Channel:     req.FormValue("channel"),
+		Username:    req.FormValue("username"),
+		Text:        req.FormValue("text"),
+		ThreadTs:    req.FormValue("thread_ts"),
+		Ts:          s.nextTs(),
+		Blocks:      req.FormValue("blocks"),
+		Attachments: req.FormValue("attachments"),
+	}
+	s.mutex.Lock()
+	s.messages = append(s.messages, m)
+	s.mutex.Unlock()
+
+	writeJSON(w, map[string]interface{}{"ok": true, "channel": m.Channel, "ts": m.Ts})
+}
+
+func (s *Server) postEphemeral(w http.ResponseWriter, req *http.Request) {
+	m := Message{
+		Channel:   req.FormValue("channel"),
+		Text:      req.FormValue("text"),
+		ThreadTs:  req.FormValue("thread_ts"),
+		Ts:        s.nextTs(),
+		User:      req.FormValue("user"),
+		Ephemeral: true,
+	}
+	if m.User == "" {
+		writeJSON(w, map[string]interface{}{"ok": false, "error": "user_not_in_channel"})
+		return
+	}
+	s.mutex.Lock()
+	s.messages = append(s.messages, m)
+	s.mutex.Unlock()
+
+	writeJSON(w, map[string]interface{}{"ok": true, "message_ts": m.Ts})
+}
+
+func (s *Server) updateMessage(w http.ResponseWriter, req *http.Request) {
+	channel, ts := req.FormValue("channel"), req.FormValue("ts")
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, m := range s.messages {
+		if m.Channel != channel || m.Ts != ts {
+			continue
+		}
+		s.messages[i].Text = req.FormValue("text")
+		if _, ok := req.Form["blocks"]; ok {
+			s.messages[i].Blocks = req.FormValue("blocks")
+		}
+		if _, ok := req.Form["attachments"]; ok {
+			s.messages[i].Attachments = req.FormValue("attachments")
+		}
+		writeJSON(w, map[string]interface{}{"ok": true, "channel": channel, "ts": ts, "text": s.messages[i].Text})
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{"ok": false, "error": "message_not_found"})
+}
+
+func (s *Server) deleteMessage(w http.ResponseWriter, req *http.Request) {
+	channel, ts := req.FormValue("channel"), req.FormValue("ts")
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, m := range s.messages {
+		if m.Channel != channel || m.Ts != ts {
+			continue
+		}
+		s.messages = append(s.messages[:i], s.messages[i+1:]...)
+		writeJSON(w, map[string]interface{}{"ok": true, "channel": channel, "ts": ts})
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{"ok": false, "error": "message_not_found"})
+}
+
+func (s *Server) openConversation(w http.ResponseWriter, req *http.Request) {
+	users := req.FormValue("users")
+	if users == "" {
+		writeJSON(w, map[string]interface{}{"ok": false, "error": "users_list_not_supplied"})
+		return
+	}
+
+	id := "D" + strings.Replace(users, ",", "", -1)
+	writeJSON(w, map[string]interface{}{"ok": true, "channel": map[string]interface{}{"id": id, "is_im": true}})
+}
+
+func (s *Server) conversationInfo(w http.ResponseWriter, req *http.Request) {
+	id := req.FormValue("channel")
+	s.mutex.Lock()
+	name, ok := s.Channels[id]
+	s.mutex.Unlock()
+	if !ok {
+		writeJSON(w, map[string]interface{}{"ok": false, "error": "channel_not_found"})
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{"ok": true, "channel": map[string]interface{}{"id": id, "name": name}})
+}
+
+func (s *Server) uploadFile(w http.ResponseWriter, req *http.Request) {
+	f := File{Filename: req.FormValue("filename"), Title: req.FormValue("title")}
+	if channels := req.FormValue("channels"); channels != "" {
+		f.Channels = strings.Split(channels, ",")
+	}
+	if file, _, err := req.FormFile("file"); err == nil {
+		f.Content, _ = ioutil.ReadAll(file)
+		file.Close()
+	} else {
+		f.Content = []byte(req.FormValue("content"))
+	}
+	s.mutex.Lock()
+	s.files = append(s.files, f)
+	id := fmt.Sprintf("F%08d", len(s.files))
+	s.mutex.Unlock()
+
+	writeJSON(w, map[string]interface{}{"ok": true, "file": map[string]interface{}{"id": id, "name": f.Filename, "title": f.Title}})
+}
+
+func (s *Server) response(w http.ResponseWriter, req *http.Request) {
+	var r Response
+	if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.mutex.Lock()
+	s.responses = append(s.responses, r)
+	s.mutex.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) teamInfo(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]interface{}{"ok": true, "team": map[string]interface{}{"id": s.TeamId, "domain": s.TeamDomain}})
+}
+
+func (s *Server) userInfo(w http.ResponseWriter, req *http.Request) {
+	id := req.FormValue("user")
+	s.mutex.Lock()
+	user, ok := s.Users[id]
+	s.mutex.Unlock()
+	if !ok {
+		writeJSON(w, map[string]interface{}{"ok": false, "error": "user_not_found"})
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{"ok": true, "user": map[string]interface{}{
+		"id":        id,
+		"name":      user.Name,
+		"real_name": user.RealName,
+		"tz":        user.TimeZone,
+		"is_bot":    user.IsBot,
+		"deleted":   user.Deleted,
+		"profile":   map[string]interface{}{"display_name": user.DisplayName, "real_name": user.RealName},
+	}})
+}
+
+func (s *Server) connectRTM(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"ok":   true,
+		"url":  "ws" + strings.TrimPrefix(s.server.URL, "http") + "/ws",
+		"self": map[string]interface{}{"id": "U00000000", "name": "bot"},
+		"team": map[string]interface{}{"id": s.TeamId, "domain": s.TeamDomain},
+	})
+}
+
+func (s *Server) openConnection(w http.ResponseWriter, req *http.Request) {
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "Bearer ") {
+		writeJSON(w, map[string]interface{}{"ok": false, "error": "not_authed"})
+		return
+	}
+	s.mutex.Lock()
+	s.opens++
+	s.mutex.Unlock()
+
+	writeJSON(w, map[string]interface{}{"ok": true, "url": "ws" + strings.TrimPrefix(s.server.URL, "http") + "/socket"})
+}
+
+// serveWebsocket answers the ping until the connection is closed.
+func (s *Server) serveWebsocket(conn *websocket.Conn) {
+	s.register(&s.connections, conn)
+	defer s.unregister(&s.connections, conn)
+
+	for {
+		var msg struct {
+			Id   int    `json:"id"`
+			Type string `json:"type"`
+		}
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			return
+		}
+		if msg.Type == "ping" {
+			websocket.JSON.Send(conn, map[string]interface{}{"type": "pong", "reply_to": msg.Id})
+		}
+	}
+}
+
+// serveSocketMode says hello and records the acknowledgements until the connection is closed.
+func (s *Server) serveSocketMode(conn *websocket.Conn) {
+	s.register(&s.sockets, conn)
+	defer s.unregister(&s.sockets, conn)
+
+	if err := websocket.JSON.Send(conn, map[string]interface{}{"type": "hello"}); err != nil {
+		return
+	}
+	for {
+		var ack Ack
+		if err := websocket.JSON.Receive(conn, &ack); err != nil {
+			return
+		}
+		s.mutex.Lock()
+		s.acks = append(s.acks, ack)
+		s.mutex.Unlock()
+	}
+}
+
+func (s *Server) register(connections *[]*websocket.Conn, conn *websocket.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	*connections = append(*connections, conn)
+	s.connected.Broadcast()
+}
+
+func (s *Server) unregister(connections *[]*websocket.Conn, conn *websocket.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, c := range *connections {
+		if c == conn {
+			*connections = append((*connections)[:i], (*connections)[i+1:]...)
+			break
+		}
+	}
+}
+
+// broadcast sends v to all connections. It waits for the connection up to ConnectionTimeout.
+func (s *Server) broadcast(connections *[]*websocket.Conn, v interface{}) error {
+	if !s.awaitConnection(connections) {
+		return ErrNotConnected
+	}
+
+	s.mutex.Lock()
+	conns := make([]*websocket.Conn, len(*connections))
+	copy(conns, *connections)
+	s.mutex.Unlock()
+
+	for _, conn := range conns {
+		if err := websocket.JSON.Send(conn, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) awaitConnection(connections *[]*websocket.Conn) bool {
+	timer := time.AfterFunc(ConnectionTimeout, func() {
+		s.mutex.Lock()
+		s.connected.Broadcast()
+		s.mutex.Unlock()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(ConnectionTimeout)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for len(*connections) == 0 && time.Now().Before(deadline) {
+		s.connected.Wait()
+	}
+
+	return len(*connections) > 0
+}
+
+// nextTs returns the unique timestamp which is newer than the start of the connector.
+func (s *Server) nextTs() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.seq++
+	return fmt.Sprintf("%d.%06d", time.Now().Unix(), s.seq)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
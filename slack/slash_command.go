@@ -0,0 +1,82 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/f110/montegrappa/bot"
+)
+
+var (
+	ErrNoResponseURL = errors.New("the slash command doesn't have response_url")
+)
+
+// SlashCommandPayload is the invocation of the slash command.
+// It is sent as the form by the HTTP endpoint and as JSON over Socket Mode.
+type SlashCommandPayload struct {
+	Command     string `json:"command"`
+	Text        string `json:"text"`
+	UserId      string `json:"user_id"`
+	ChannelId   string `json:"channel_id"`
+	ResponseURL string `json:"response_url"`
+	TriggerId   string `json:"trigger_id"`
+}
+
+// slashCommandResponse is the message which answers the slash command.
+type slashCommandResponse struct {
+	ResponseType string `json:"response_type,omitempty"`
+	Text         string `json:"text"`
+}
+
+// RespondSlashCommand sends the delayed response to response_url of the slash command.
+func (w *webClient) RespondSlashCommand(event *bot.Event, text string, inChannel bool) error {
+	if event.SlashCommand == nil || event.SlashCommand.ResponseURL == "" {
+		return ErrNoResponseURL
+	}
+
+	buf, err := json.Marshal(newSlashCommandResponse(text, inChannel))
+	if err != nil {
+		return err
+	}
+	res, err := w.httpClient.Post(event.SlashCommand.ResponseURL, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return statusCodeError{Code: res.StatusCode, Status: res.Status}
+	}
+
+	return nil
+}
+
+// toSlashCommandEvent converts the payload into bot.Event and resolves the user of the event.
+func (w *webClient) toSlashCommandEvent(payload *SlashCommandPayload) *bot.Event {
+	return w.resolve(toSlashCommandEvent(payload))
+}
+
+// toSlashCommandEvent converts the payload into bot.Event.
+func toSlashCommandEvent(payload *SlashCommandPayload) *bot.Event {
+	event := &bot.Event{
+		Type:         bot.SlashCommandEvent,
+		Message:      payload.Text,
+		Channel:      payload.ChannelId,
+		Timestamp:    time.Now(),
+		SlashCommand: bot.NewSlashCommand(payload.Command, payload.ResponseURL),
+	}
+	event.User.Id = payload.UserId
+
+	return event
+}
+
+// newSlashCommandResponse returns the response. The acknowledgement is shown only to the user if inChannel is false.
+func newSlashCommandResponse(text string, inChannel bool) *slashCommandResponse {
+	if inChannel {
+		return &slashCommandResponse{ResponseType: "in_channel", Text: text}
+	}
+
+	return &slashCommandResponse{ResponseType: "ephemeral", Text: text}
+}
@@ -0,0 +1,186 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/f110/montegrappa/bot"
+	"github.com/nlopes/slack/slackevents"
+	"golang.org/x/net/websocket"
+)
+
+// SocketModeConnector receives events through Socket Mode.
+// The connector doesn't require any public endpoint because the connection is initiated by the bot.
+type SocketModeConnector struct {
+	webClient
+
+	appToken   string
+	eventChan  chan *bot.Event
+	connection *websocket.Conn
+	// mutex serializes writing to connection.
+	mutex sync.Mutex
+}
+
+// Envelope is the message which is sent by Slack over Socket Mode.
+type Envelope struct {
+	Type       string          `json:"type"`
+	EnvelopeId string          `json:"envelope_id"`
+	Payload    json.RawMessage `json:"payload"`
+	Reason     string          `json:"reason"`
+}
+
+type acknowledge struct {
+	EnvelopeId string      `json:"envelope_id"`
+	Payload    interface{} `json:"payload,omitempty"`
+}
+
+type connectionsOpenResponse struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+	Url   string `json:"url"`
+}
+
+// NewSocketModeConnector returns the connector.
+// token is a bot token for Web API and appToken is an app-level token which has connections:write scope.
+func NewSocketModeConnector(teamId, token, appToken string) *SocketModeConnector {
+	return &SocketModeConnector{
+		webClient: newWebClient(teamId, token),
+		appToken:  appToken,
+		eventChan: make(chan *bot.Event),
+	}
+}
+
+func (connector *SocketModeConnector) Connect() error {
+	ws, err := connector.open()
+	if err != nil {
+		return err
+	}
+
+	connector.connection = ws
+	return nil
+}
+
+func (connector *SocketModeConnector) Listen() error {
+	for {
+		var envelope Envelope
+		if err := websocket.JSON.Receive(connector.connection, &envelope); err != nil {
+			connector.connection.Close()
+			return err
+		}
+
+		// The slash command is acknowledged with the answer of the handler.
+		if envelope.EnvelopeId != "" && envelope.Type != "slash_commands" {
+			if err := connector.send(&acknowledge{EnvelopeId: envelope.EnvelopeId}); err != nil {
+				connector.connection.Close()
+				return err
+			}
+		}
+
+		switch envelope.Type {
+		case "hello":
+			continue
+		case "disconnect":
+			log.Printf("disconnect requested: %s", envelope.Reason)
+			ws, err := connector.open()
+			if err != nil {
+				connector.connection.Close()
+				return err
+			}
+			connector.mutex.Lock()
+			connector.connection.Close()
+			connector.connection = ws
+			connector.mutex.Unlock()
+		case "events_api":
+			var callback slackevents.EventsAPICallbackEvent
+			if err := json.Unmarshal(envelope.Payload, &callback); err != nil || callback.InnerEvent == nil {
+				continue
+			}
+			if botEvent := connector.toBotEvent(*callback.InnerEvent); botEvent != nil {
+				connector.eventChan <- botEvent
+			}
+		case "interactive":
+			if botEvent := connector.toInteractionEvent(envelope.Payload); botEvent != nil {
+				connector.eventChan <- botEvent
+			}
+		case "slash_commands":
+			var payload SlashCommandPayload
+			if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+				continue
+			}
+			go connector.slashCommand(envelope.EnvelopeId, &payload)
+		}
+	}
+}
+
+func (*SocketModeConnector) Async() bool {
+	return true
+}
+
+func (connector *SocketModeConnector) ReceivedEvent() chan *bot.Event {
+	return connector.eventChan
+}
+
+func (connector *SocketModeConnector) Idle() chan bool {
+	return nil
+}
+
+// WithIndicate does nothing because the typing indicator is only available on RTM API.
+func (connector *SocketModeConnector) WithIndicate(_ string) context.CancelFunc {
+	_, cancel := context.WithCancel(context.Background())
+	return cancel
+}
+
+// slashCommand passes the slash command to the bot and acknowledges the envelope with the answer of the handler.
+// It is called in its own goroutine since resolving the user calls Web API.
+func (connector *SocketModeConnector) slashCommand(envelopeId string, payload *SlashCommandPayload) {
+	botEvent := connector.toSlashCommandEvent(payload)
+	connector.eventChan <- botEvent
+
+	ack := &acknowledge{EnvelopeId: envelopeId}
+	if text := botEvent.SlashCommand.WaitAck(bot.SlashCommandAckTimeout); text != "" {
+		ack.Payload = newSlashCommandResponse(text, false)
+	}
+	if err := connector.send(ack); err != nil {
+		log.Printf("failed to acknowledge %s: %s", envelopeId, err)
+	}
+}
+
+func (connector *SocketModeConnector) send(v interface{}) error {
+	connector.mutex.Lock()
+	defer connector.mutex.Unlock()
+
+	return websocket.JSON.Send(connector.connection, v)
+}
+
+// open retrieves the endpoint from apps.connections.open and dials it.
+func (connector *SocketModeConnector) open() (*websocket.Conn, error) {
+	req, err := http.NewRequest(http.MethodPost, connector.endpoint("apps.connections.open"), strings.NewReader(url.Values{}.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+connector.appToken)
+
+	res, err := connector.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var conn connectionsOpenResponse
+	if err := json.NewDecoder(res.Body).Decode(&conn); err != nil {
+		return nil, err
+	}
+	if !conn.Ok {
+		return nil, errors.New(conn.Error)
+	}
+
+	log.Printf("start connect to %s", conn.Url)
+	return websocket.Dial(conn.Url, "", "http://localhost")
+}
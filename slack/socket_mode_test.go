@@ -0,0 +1,182 @@
+package slack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/f110/montegrappa/bot"
+	"github.com/f110/montegrappa/slack/slacktest"
+)
+
+const testTimeout = 3 * time.Second
+
+func TestSocketModeConnector(t *testing.T) {
+	server := slacktest.NewServer()
+	defer server.Close()
+	server.Users["U1"] = slacktest.User{Name: "alice"}
+
+	connector := NewSocketModeConnector("T1", "xoxb-token", "xapp-token")
+	connector.SetAPIURL(server.APIURL())
+	listen := func() chan error {
+		if err := connector.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- connector.Listen()
+		}()
+		return errCh
+	}
+
+	errCh := listen()
+	id := sendMessageEnvelope(t, server, "hello")
+	event := receiveEvent(t, connector.ReceivedEvent())
+	if event.Type != bot.MessageEvent || event.Message != "hello" || event.User.Name != "alice" {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	waitAck(t, server, id)
+
+	server.DropSockets()
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("Listen returned without error after the connection was dropped")
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("Listen didn't return after the connection was dropped")
+	}
+
+	listen()
+	if server.Opens() != 2 {
+		t.Fatalf("expected apps.connections.open to be called twice: %d", server.Opens())
+	}
+	id = sendMessageEnvelope(t, server, "again")
+	event = receiveEvent(t, connector.ReceivedEvent())
+	if event.Message != "again" {
+		t.Fatalf("unexpected event after reconnecting: %+v", event)
+	}
+	waitAck(t, server, id)
+}
+
+func TestSocketModeConnector_Disconnect(t *testing.T) {
+	server := slacktest.NewServer()
+	defer server.Close()
+
+	connector := NewSocketModeConnector("T1", "xoxb-token", "xapp-token")
+	connector.SetAPIURL(server.APIURL())
+	if err := connector.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- connector.Listen()
+	}()
+
+	id, err := server.SendEnvelope("disconnect", map[string]string{"reason": "refresh_requested"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitAck(t, server, id)
+
+	// The message is sent until it reaches the new connection since the old one is closed asynchronously.
+	deadline := time.Now().Add(testTimeout)
+	for server.Opens() != 2 || !trySendMessageEnvelope(server, "after disconnect") {
+		if time.Now().After(deadline) {
+			t.Fatalf("the connection is not reopened: %d", server.Opens())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	event := receiveEvent(t, connector.ReceivedEvent())
+	if event.Message != "after disconnect" {
+		t.Fatalf("unexpected event after the disconnect: %+v", event)
+	}
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("Listen returned by the disconnect request: %v", err)
+	default:
+	}
+}
+
+func TestSocketModeConnector_SlashCommand(t *testing.T) {
+	server := slacktest.NewServer()
+	defer server.Close()
+
+	connector := NewSocketModeConnector("T1", "xoxb-token", "xapp-token")
+	connector.SetAPIURL(server.APIURL())
+	if err := connector.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	go connector.Listen()
+
+	id, err := server.SendEnvelope("slash_commands", map[string]string{"command": "/deploy", "text": "prod", "user_id": "U1", "channel_id": "C1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := receiveEvent(t, connector.ReceivedEvent())
+	if event.Type != bot.SlashCommandEvent || event.SlashCommand.Name != "/deploy" {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	event.SlashCommand.Ack("deploying")
+
+	ack := waitAck(t, server, id)
+	if string(ack.Payload) == "" {
+		t.Fatal("the acknowledgement of the slash command doesn't have the payload")
+	}
+}
+
+func sendMessageEnvelope(t *testing.T, server *slacktest.Server, text string) string {
+	t.Helper()
+
+	id, err := server.SendEnvelope("events_api", messagePayload(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+// trySendMessageEnvelope is sendMessageEnvelope which reports the failure instead of failing the test.
+func trySendMessageEnvelope(server *slacktest.Server, text string) bool {
+	_, err := server.SendEnvelope("events_api", messagePayload(text))
+
+	return err == nil
+}
+
+func messagePayload(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "event_callback",
+		"event_id": "Ev" + text,
+		"event":    map[string]string{"type": "message", "channel": "C1", "user": "U1", "text": text, "ts": "1.000001"},
+	}
+}
+
+func receiveEvent(t *testing.T, ch chan *bot.Event) *bot.Event {
+	t.Helper()
+
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for the event")
+	}
+
+	return nil
+}
+
+func waitAck(t *testing.T, server *slacktest.Server, envelopeId string) slacktest.Ack {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for time.Now().Before(deadline) {
+		for _, ack := range server.Acks() {
+			if ack.EnvelopeId == envelopeId {
+				return ack
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("envelope %s is not acknowledged", envelopeId)
+
+	return slacktest.Ack{}
+}
@@ -0,0 +1,275 @@
+package slack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/f110/montegrappa/bot"
+	"github.com/nlopes/slack"
+)
+
+// webClient implements the parts of bot.Connector which only use the Web API.
+// It is shared by the connectors that differ in how they receive events.
+// The messages are sent through outboundQueue, which keeps the order in each channel and honours the rate limit.
+type webClient struct {
+	teamId string
+	domain string
+	token  string
+	apiURL string
+	client *slack.Client
+	// httpClient is used for the methods which aren't supported by client.
+	httpClient *http.Client
+	directory  *directory
+	queue      *outboundQueue
+}
+
+// endpointTransport sends the request for the Web API to another base URL.
+type endpointTransport struct {
+	apiURL string
+}
+
+func newWebClient(teamId, token string) webClient {
+	return webClient{teamId: teamId, token: token, client: slack.New(token), httpClient: &http.Client{}, directory: newDirectory(), queue: newOutboundQueue()}
+}
+
+// SetAPIURL changes the base URL of the Web API, e.g. the URL of slacktest.Server.
+// It must be called before Connect.
+func (w *webClient) SetAPIURL(apiURL string) {
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	w.apiURL = apiURL
+	w.client = slack.New(w.token, slack.OptionHTTPClient(&http.Client{Transport: &endpointTransport{apiURL: apiURL}}))
+}
+
+// endpoint returns the URL of the Web API method.
+func (w *webClient) endpoint(method string) string {
+	if w.apiURL == "" {
+		return slack.APIURL + method
+	}
+
+	return w.apiURL + method
+}
+
+func (w *webClient) Client() *slack.Client {
+	return w.client
+}
+
+func (w *webClient) Send(event *bot.Event, username string, text string) (*bot.SentMessage, error) {
+	ts, err := w.postMessage(event.Channel, slack.MsgOptionUsername(username), slack.MsgOptionText(text, false))
+	if err != nil {
+		return nil, err
+	}
+
+	return &bot.SentMessage{Message: text, Channel: event.Channel, Timestamp: ts}, nil
+}
+
+func (w *webClient) SendWithConfirm(event *bot.Event, username, text string) (string, error) {
+	return w.postMessage(event.Channel, slack.MsgOptionUsername(username), slack.MsgOptionText(text, false))
+}
+
+func (w *webClient) SendInThread(event *bot.Event, username, threadTs, text string) (*bot.SentMessage, error) {
+	ts, err := w.postMessage(event.Channel, slack.MsgOptionUsername(username), slack.MsgOptionText(text, false), slack.MsgOptionTS(threadTs))
+	if err != nil {
+		return nil, err
+	}
+
+	return &bot.SentMessage{Message: text, Channel: event.Channel, Timestamp: ts}, nil
+}
+
+func (w *webClient) SendPrivate(event *bot.Event, userId, text string) (*bot.SentMessage, error) {
+	channelId, err := w.queue.do("conversations.open", userId, func() (string, error) {
+		channel, _, _, err := w.client.OpenConversation(&slack.OpenConversationParameters{Users: []string{userId}})
+		if err != nil {
+			return "", err
+		}
+		return channel.ID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := w.postMessage(channelId, slack.MsgOptionText(text, false))
+	if err != nil {
+		return nil, err
+	}
+
+	return &bot.SentMessage{Message: text, Channel: channelId, Timestamp: ts}, nil
+}
+
+// SendEphemeral posts the message which is shown only to the user.
+// If the event is in the thread, the message is posted to the thread.
+func (w *webClient) SendEphemeral(event *bot.Event, userId, text string) error {
+	options := []slack.MsgOption{slack.MsgOptionText(text, false)}
+	if event.ThreadTs != "" {
+		options = append(options, slack.MsgOptionTS(event.ThreadTs))
+	}
+
+	_, err := w.queue.do("chat.postEphemeral", event.Channel, func() (string, error) {
+		return w.client.PostEphemeral(event.Channel, userId, options...)
+	})
+
+	return err
+}
+
+// Attach uploads the file. The content is read into memory, so that the upload can be retried.
+func (w *webClient) Attach(event *bot.Event, fileName string, file io.Reader, title string) error {
+	buf, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.queue.do("files.upload", event.Channel, func() (string, error) {
+		_, err := w.client.UploadFile(slack.FileUploadParameters{
+			Filename: fileName,
+			Channels: []string{event.Channel},
+			Reader:   bytes.NewReader(buf),
+			Title:    title,
+			Filetype: "auto",
+		})
+		return "", err
+	})
+
+	return err
+}
+
+// UpdateMessage replaces the text of the message which has been sent.
+func (w *webClient) UpdateMessage(message *bot.SentMessage, text string) (*bot.SentMessage, error) {
+	_, err := w.queue.do("chat.update", message.Channel, func() (string, error) {
+		_, ts, _, err := w.client.UpdateMessage(message.Channel, message.Timestamp, slack.MsgOptionText(text, false))
+		return ts, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &bot.SentMessage{Message: text, Channel: message.Channel, Timestamp: message.Timestamp}, nil
+}
+
+func (w *webClient) DeleteMessage(message *bot.SentMessage) error {
+	_, err := w.queue.do("chat.delete", message.Channel, func() (string, error) {
+		_, ts, err := w.client.DeleteMessage(message.Channel, message.Timestamp)
+		return ts, err
+	})
+
+	return err
+}
+
+// OnSendFailure sets the callback which is called when the message is given up.
+func (w *webClient) OnSendFailure(f OnSendFailure) {
+	w.queue.onFailure = f
+}
+
+// postMessage sends the message through the queue of the channel and returns the timestamp of the message.
+func (w *webClient) postMessage(channel string, options ...slack.MsgOption) (string, error) {
+	return w.queue.do("chat.postMessage", channel, func() (string, error) {
+		_, ts, err := w.client.PostMessage(channel, options...)
+		return ts, err
+	})
+}
+
+func (w *webClient) GetPermalink(event *bot.Event) string {
+	return fmt.Sprintf("https://%s.slack.com/archives/%s/p%s", w.teamDomain(), event.Channel, strings.Replace(event.Ts, ".", "", -1))
+}
+
+func (w *webClient) teamDomain() string {
+	if w.domain == "" {
+		info, err := w.client.GetTeamInfo()
+		if err != nil {
+			return ""
+		}
+		w.domain = info.Domain
+	}
+
+	return w.domain
+}
+
+// GetChannelInfo returns the channel. The result is cached for CacheTTL.
+func (w *webClient) GetChannelInfo(channelId string) (*bot.ChannelInfo, error) {
+	if c, ok := w.directory.channel(channelId); ok {
+		return &c, nil
+	}
+
+	channel, err := w.client.GetConversationInfo(channelId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var res bot.ChannelInfo
+	res.Name = channel.Name
+	res.Id = channel.ID
+	w.directory.setChannel(res)
+	return &res, nil
+}
+
+// GetUser returns the user. The result is cached for CacheTTL.
+func (w *webClient) GetUser(userId string) (*bot.User, error) {
+	if u, ok := w.directory.user(userId); ok {
+		return &u, nil
+	}
+
+	user, err := w.client.GetUserInfo(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	res := bot.User{
+		Id:          user.ID,
+		Name:        user.Name,
+		RealName:    user.RealName,
+		DisplayName: user.Profile.DisplayName,
+		TimeZone:    user.TZ,
+		IsBot:       user.IsBot,
+		IsDeleted:   user.Deleted,
+	}
+	w.directory.setUser(res)
+	return &res, nil
+}
+
+// toBotEvent converts the payload into bot.Event and resolves the user of the event.
+// The cache is invalidated by user_change and channel_rename.
+func (w *webClient) toBotEvent(buf []byte) *bot.Event {
+	w.directory.invalidate(buf)
+
+	return w.resolve(toBotEvent(buf))
+}
+
+// toInteractionEvent converts the payload of interactive components and resolves the user of the event.
+func (w *webClient) toInteractionEvent(buf []byte) *bot.Event {
+	return w.resolve(toInteractionEvent(buf))
+}
+
+// resolve fills the user of the event. If the user can't be resolved, only Id is set.
+func (w *webClient) resolve(event *bot.Event) *bot.Event {
+	if event == nil || event.User.Id == "" {
+		return event
+	}
+	if u, err := w.GetUser(event.User.Id); err == nil {
+		event.User = *u
+	}
+
+	return event
+}
+
+func (w *webClient) GetUserGroupMembers(groupId string) ([]string, error) {
+	return w.client.GetUserGroupMembers(groupId)
+}
+
+func (t *endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if u := req.URL.String(); strings.HasPrefix(u, slack.APIURL) {
+		target, err := url.Parse(t.apiURL + strings.TrimPrefix(u, slack.APIURL))
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.URL = target
+		req.Host = target.Host
+	}
+
+	return http.DefaultTransport.RoundTrip(req)
+}
@@ -0,0 +1,220 @@
+package slack
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/f110/montegrappa/bot"
+	"github.com/f110/montegrappa/slack/slacktest"
+)
+
+func TestConnector_Send(t *testing.T) {
+	server, connector := newTestConnector()
+	defer server.Close()
+
+	sent, err := connector.Send(&bot.Event{Channel: "C1"}, "bot", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message: %d", len(messages))
+	}
+	if messages[0].Channel != "C1" || messages[0].Username != "bot" || messages[0].Text != "hello" {
+		t.Fatalf("unexpected message: %+v", messages[0])
+	}
+	if sent.Channel != "C1" || sent.Timestamp != messages[0].Ts || sent.Message != "hello" {
+		t.Fatalf("unexpected sent message: %+v", sent)
+	}
+}
+
+func TestConnector_SendInThread(t *testing.T) {
+	server, connector := newTestConnector()
+	defer server.Close()
+
+	sent, err := connector.SendInThread(&bot.Event{Channel: "C1"}, "bot", "1500000000.000100", "reply")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 || messages[0].ThreadTs != "1500000000.000100" {
+		t.Fatalf("the message is not sent to the thread: %+v", messages)
+	}
+	if sent.Channel != "C1" || sent.Timestamp != messages[0].Ts || sent.Message != "reply" {
+		t.Fatalf("unexpected sent message: %+v", sent)
+	}
+}
+
+func TestConnector_SendPrivate(t *testing.T) {
+	server, connector := newTestConnector()
+	defer server.Close()
+
+	sent, err := connector.SendPrivate(&bot.Event{Channel: "C1"}, "U1", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message: %d", len(messages))
+	}
+	if messages[0].Channel != "DU1" || messages[0].Text != "secret" {
+		t.Fatalf("the message is not sent to the direct message: %+v", messages[0])
+	}
+	if sent.Channel != "DU1" {
+		t.Fatalf("unexpected channel: %s", sent.Channel)
+	}
+}
+
+func TestConnector_Attach(t *testing.T) {
+	server, connector := newTestConnector()
+	defer server.Close()
+
+	if err := connector.Attach(&bot.Event{Channel: "C1"}, "result.txt", strings.NewReader("ok"), "Result"); err != nil {
+		t.Fatal(err)
+	}
+
+	files := server.Files()
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file: %d", len(files))
+	}
+	f := files[0]
+	if f.Filename != "result.txt" || f.Title != "Result" || string(f.Content) != "ok" {
+		t.Fatalf("unexpected file: %+v", f)
+	}
+	if len(f.Channels) != 1 || f.Channels[0] != "C1" {
+		t.Fatalf("unexpected channels: %v", f.Channels)
+	}
+}
+
+func TestConnector_GetChannelInfo(t *testing.T) {
+	server, connector := newTestConnector()
+	defer server.Close()
+	server.Channels["C1"] = "general"
+
+	info, err := connector.GetChannelInfo("C1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Id != "C1" || info.Name != "general" {
+		t.Fatalf("unexpected channel: %+v", info)
+	}
+
+	// The second call is answered from the cache.
+	server.Fail("conversations.info", slacktest.Failure{Error: "internal_error"})
+	if info, err := connector.GetChannelInfo("C1"); err != nil || info.Name != "general" {
+		t.Fatalf("the channel is not cached: %+v, %v", info, err)
+	}
+
+	if _, err := connector.GetChannelInfo("C2"); err == nil {
+		t.Fatal("expected the error for the unknown channel")
+	}
+}
+
+func TestConnector_GetPermalink(t *testing.T) {
+	server, connector := newTestConnector()
+	defer server.Close()
+
+	link := connector.GetPermalink(&bot.Event{Channel: "C1", Ts: "1500000000.000100"})
+	if link != "https://slacktest.slack.com/archives/C1/p1500000000000100" {
+		t.Fatalf("unexpected permalink: %s", link)
+	}
+}
+
+func TestConnector_SendRateLimited(t *testing.T) {
+	server, connector := newTestConnector()
+	defer server.Close()
+	server.Fail("chat.postMessage", slacktest.Failure{Status: http.StatusTooManyRequests, RetryAfter: time.Second})
+
+	start := time.Now()
+	if _, err := connector.Send(&bot.Event{Channel: "C1"}, "bot", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("the message is retried before Retry-After: %s", elapsed)
+	}
+	if len(server.Messages()) != 1 {
+		t.Fatalf("expected 1 message: %d", len(server.Messages()))
+	}
+}
+
+func TestConnector_SendRateLimitedWithoutRetryAfter(t *testing.T) {
+	defer func(backoff time.Duration) { RetryBackoff = backoff }(RetryBackoff)
+	RetryBackoff = 10 * time.Millisecond
+
+	server, connector := newTestConnector()
+	defer server.Close()
+	server.Fail("chat.postMessage", slacktest.Failure{Status: http.StatusTooManyRequests})
+
+	// The rich message is posted by callAPI instead of the Web API client.
+	if _, err := connector.SendRich(&bot.Event{Channel: "C1"}, "bot", "", &bot.RichMessage{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.Messages()) != 1 {
+		t.Fatalf("expected 1 message: %d", len(server.Messages()))
+	}
+}
+
+func TestConnector_SendTransientFailure(t *testing.T) {
+	defer func(backoff time.Duration) { RetryBackoff = backoff }(RetryBackoff)
+	RetryBackoff = 10 * time.Millisecond
+
+	server, connector := newTestConnector()
+	defer server.Close()
+	server.Fail("chat.postMessage",
+		slacktest.Failure{Status: http.StatusInternalServerError},
+		slacktest.Failure{Error: "service_unavailable"},
+	)
+
+	if _, err := connector.Send(&bot.Event{Channel: "C1"}, "bot", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.Messages()) != 1 {
+		t.Fatalf("expected 1 message: %d", len(server.Messages()))
+	}
+}
+
+func TestConnector_SendGiveUp(t *testing.T) {
+	defer func(backoff time.Duration, retry int) { RetryBackoff, MaxRetry = backoff, retry }(RetryBackoff, MaxRetry)
+	RetryBackoff = 10 * time.Millisecond
+	MaxRetry = 1
+
+	server, connector := newTestConnector()
+	defer server.Close()
+	var failed []string
+	connector.OnSendFailure(func(method, channel string, _ error) {
+		failed = append(failed, method+" "+channel)
+	})
+
+	server.Fail("chat.postMessage", slacktest.Failure{Error: "channel_not_found"})
+	if _, err := connector.Send(&bot.Event{Channel: "C1"}, "bot", "hello"); err == nil {
+		t.Fatal("expected the permanent error")
+	}
+
+	server.Fail("chat.postMessage",
+		slacktest.Failure{Status: http.StatusInternalServerError},
+		slacktest.Failure{Status: http.StatusInternalServerError},
+	)
+	if _, err := connector.Send(&bot.Event{Channel: "C1"}, "bot", "hello"); err == nil {
+		t.Fatal("expected the error after MaxRetry")
+	}
+
+	if len(failed) != 2 || failed[0] != "chat.postMessage C1" {
+		t.Fatalf("unexpected failures: %v", failed)
+	}
+	if len(server.Messages()) != 0 {
+		t.Fatalf("the message is sent: %+v", server.Messages())
+	}
+}
+
+func newTestConnector() (*slacktest.Server, *Connector) {
+	server := slacktest.NewServer()
+	connector := NewConnector("T1", "xoxb-token")
+	connector.SetAPIURL(server.APIURL())
+
+	return server, connector
+}